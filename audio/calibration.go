@@ -0,0 +1,143 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+const (
+	CurveLinear = "linear"
+	CurveLog    = "log"
+	CurveDB     = "db"
+
+	logTaperDecades = 2.0
+)
+
+var ErrInvalidCalibration = errors.New("invalid volume calibration")
+
+type Calibration struct {
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+	Curve string `json:"curve"`
+}
+
+var (
+	calibrationMu sync.RWMutex
+	calibrations  = map[string]Calibration{}
+)
+
+func DefaultCalibration() Calibration {
+	return Calibration{Min: 0, Max: 100, Curve: CurveLinear}
+}
+
+func (c Calibration) Validate() error {
+	if c.Min < 0 || c.Max > 100 || c.Min >= c.Max {
+		return fmt.Errorf("%w: min and max must satisfy 0 <= min < max <= 100, got %d and %d", ErrInvalidCalibration, c.Min, c.Max)
+	}
+	switch c.Curve {
+	case CurveLinear, CurveLog, CurveDB:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown curve %q (expected %s, %s or %s)", ErrInvalidCalibration, c.Curve, CurveLinear, CurveLog, CurveDB)
+	}
+}
+
+func (c Calibration) mapped() bool {
+	return c.Curve == CurveDB
+}
+
+func (c Calibration) Apply(volume int) int {
+	if volume <= 0 {
+		return 0
+	}
+	x := float64(min(volume, 100)) / 100
+	if c.Curve == CurveLog {
+		x = (math.Pow(10, logTaperDecades*x) - 1) / (math.Pow(10, logTaperDecades) - 1)
+	}
+	return int(math.Round(float64(c.Min) + float64(c.Max-c.Min)*x))
+}
+
+func (c Calibration) Invert(percent int) int {
+	if percent <= 0 {
+		return 0
+	}
+	first, last := 0, 0
+	for volume := 1; volume <= 100; volume++ {
+		applied := c.Apply(volume)
+		if applied > percent {
+			break
+		}
+		if applied == percent && first == 0 {
+			first = volume
+		}
+		last = volume
+	}
+	switch {
+	case first > 0:
+		return (first + last + 1) / 2
+	case last > 0:
+		return last
+	default:
+		return 1
+	}
+}
+
+func ParseCalibrations(data string) (map[string]Calibration, error) {
+	var raw map[string]struct {
+		Min   *int   `json:"min"`
+		Max   *int   `json:"max"`
+		Curve string `json:"curve"`
+	}
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCalibration, err)
+	}
+	parsed := make(map[string]Calibration, len(raw))
+	for sink, entry := range raw {
+		calibration := DefaultCalibration()
+		if entry.Min != nil {
+			calibration.Min = *entry.Min
+		}
+		if entry.Max != nil {
+			calibration.Max = *entry.Max
+		}
+		if entry.Curve != "" {
+			calibration.Curve = strings.ToLower(entry.Curve)
+		}
+		if err := calibration.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", sink, err)
+		}
+		parsed[sink] = calibration
+	}
+	return parsed, nil
+}
+
+func SetCalibrations(c map[string]Calibration) {
+	calibrationMu.Lock()
+	defer calibrationMu.Unlock()
+	calibrations = c
+}
+
+func lookupCalibration(card, control string) (Calibration, bool) {
+	calibrationMu.RLock()
+	defer calibrationMu.RUnlock()
+	if calibration, ok := calibrations[card+"/"+control]; ok {
+		return calibration, true
+	}
+	if card == alsaCard {
+		if calibration, ok := calibrations[control]; ok {
+			return calibration, true
+		}
+	}
+	return Calibration{}, false
+}
+
+func CalibrationFor(card, control string) Calibration {
+	if calibration, ok := lookupCalibration(card, control); ok {
+		return calibration
+	}
+	return DefaultCalibration()
+}
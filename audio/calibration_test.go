@@ -0,0 +1,77 @@
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCalibrationInvert(t *testing.T) {
+	calibrations := []Calibration{
+		{Min: 0, Max: 100, Curve: CurveLinear},
+		{Min: 20, Max: 80, Curve: CurveLinear},
+		{Min: 20, Max: 80, Curve: CurveLog},
+		{Min: 0, Max: 100, Curve: CurveLog},
+		{Min: 10, Max: 60, Curve: CurveDB},
+	}
+
+	for _, c := range calibrations {
+		if got := c.Invert(0); got != 0 {
+			t.Errorf("%+v: Invert(0) = %d, want 0", c, got)
+		}
+		for volume := 1; volume <= 100; volume++ {
+			percent := c.Apply(volume)
+			if got := c.Apply(c.Invert(percent)); got != percent {
+				t.Errorf("%+v: Apply(Invert(%d)) = %d, want %d", c, percent, got, percent)
+			}
+		}
+	}
+}
+
+func TestCalibrationInvertLowRange(t *testing.T) {
+	c := Calibration{Min: 20, Max: 80, Curve: CurveLog}
+	if got := c.Invert(c.Min); got <= 1 {
+		t.Errorf("Invert(%d) = %d, want the middle of the volumes mapped to min", c.Min, got)
+	}
+	if got := c.Invert(c.Min - 5); got != 1 {
+		t.Errorf("Invert(%d) = %d, want 1", c.Min-5, got)
+	}
+	if got := c.Invert(c.Max); got != 100 {
+		t.Errorf("Invert(%d) = %d, want 100", c.Max, got)
+	}
+}
+
+func TestParseCalibrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Calibration
+		wantErr bool
+	}{
+		{name: "defaults", data: `{"PCM":{}}`, want: Calibration{Min: 0, Max: 100, Curve: CurveLinear}},
+		{name: "curve case", data: `{"PCM":{"min":10,"curve":"LOG"}}`, want: Calibration{Min: 10, Max: 100, Curve: CurveLog}},
+		{name: "explicit max zero", data: `{"PCM":{"max":0}}`, wantErr: true},
+		{name: "min above max", data: `{"PCM":{"min":60,"max":40}}`, wantErr: true},
+		{name: "max above 100", data: `{"PCM":{"max":120}}`, wantErr: true},
+		{name: "negative min", data: `{"PCM":{"min":-1}}`, wantErr: true},
+		{name: "unknown curve", data: `{"PCM":{"curve":"cubic"}}`, wantErr: true},
+		{name: "invalid json", data: `{"PCM":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCalibrations(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCalibration) {
+					t.Fatalf("ParseCalibrations() error = %v, want %v", err, ErrInvalidCalibration)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCalibrations() error = %v", err)
+			}
+			if got["PCM"] != tt.want {
+				t.Errorf("ParseCalibrations() = %+v, want %+v", got["PCM"], tt.want)
+			}
+		})
+	}
+}
@@ -1,101 +1,378 @@
 package audio
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"jacadi/logging"
+	"jacadi/tracing"
+)
+
+var ErrSessionNotFound = errors.New("folder session not found")
+
+const (
+	SessionPlaying     = "playing"
+	SessionPaused      = "paused"
+	SessionInterrupted = "interrupted"
+	SessionQueued      = "queued"
 )
 
 type Coordinator struct {
-	mu        sync.Mutex
-	volumeMu  sync.Mutex
-	aplay     *AplayPlayer
-	folder    *FolderPlayer
-	resumeDir string
-	logger    *slog.Logger
+	mu       sync.Mutex
+	volumeMu sync.Mutex
+	aplay    *AplayPlayer
+	folder   *FolderPlayer
+	sessions []*folderSession
+	active   *folderSession
+	playing  atomic.Int32
+	unmute   bool
+	logger   *slog.Logger
+}
+
+type volumeOverride struct {
+	snapshot volumeSnapshot
+	unmuted  bool
+}
+
+type folderSession struct {
+	id       string
+	dir      string
+	priority int
+	options  FolderOptions
+	volume   *int
+	deadline time.Time
+	point    ResumePoint
+}
+
+type FolderSession struct {
+	ID       string  `json:"id"`
+	Dir      string  `json:"dir"`
+	Priority int     `json:"priority"`
+	State    string  `json:"state"`
+	Track    string  `json:"track,omitempty"`
+	Position float64 `json:"position,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+}
+
+func (s *folderSession) remaining() (FolderOptions, bool) {
+	opts := s.options
+	if s.deadline.IsZero() {
+		return opts, true
+	}
+	opts.MaxDuration = time.Until(s.deadline)
+	return opts, opts.MaxDuration > 0
 }
 
-func NewCoordinator(aplay *AplayPlayer, folder *FolderPlayer, logger *slog.Logger) *Coordinator {
-	return &Coordinator{
+type Status struct {
+	Playing       bool   `json:"playing"`
+	FolderPlaying bool   `json:"folder_playing"`
+	Folder        string `json:"folder,omitempty"`
+	Session       string `json:"session,omitempty"`
+	QueuedFolders int    `json:"queued_folders,omitempty"`
+}
+
+func NewCoordinator(aplay *AplayPlayer, folder *FolderPlayer, unmute bool, logger *slog.Logger) *Coordinator {
+	c := &Coordinator{
 		aplay:  aplay,
 		folder: folder,
+		unmute: unmute,
 		logger: logger,
 	}
+	folder.OnFinish(c.folderFinished)
+	return c
+}
+
+func (c *Coordinator) folderFinished(dir string) {
+	c.mu.Lock()
+	if c.active == nil || c.active.dir != dir || c.folder.IsPlaying() {
+		c.mu.Unlock()
+		return
+	}
+	c.logger.Info("folder session ended", "session", c.active.id, "dir", dir)
+	c.removeLocked(c.active)
+	c.active = nil
+	c.mu.Unlock()
+
+	c.resumeIdle(context.Background())
+}
+
+func (c *Coordinator) resumeIdle(ctx context.Context) {
+	c.volumeMu.Lock()
+	defer c.volumeMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active == nil && c.playing.Load() == 0 {
+		c.resumeTopLocked(ctx)
+	}
+}
+
+func (c *Coordinator) topLocked() *folderSession {
+	if len(c.sessions) == 0 {
+		return nil
+	}
+	return c.sessions[len(c.sessions)-1]
+}
+
+func (c *Coordinator) findLocked(id string) *folderSession {
+	for _, s := range c.sessions {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (c *Coordinator) removeLocked(session *folderSession) {
+	for i, s := range c.sessions {
+		if s == session {
+			c.sessions = append(c.sessions[:i], c.sessions[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Coordinator) pushLocked(session *folderSession) {
+	i := len(c.sessions)
+	for i > 0 && c.sessions[i-1].priority > session.priority {
+		i--
+	}
+	c.sessions = append(c.sessions, nil)
+	copy(c.sessions[i+1:], c.sessions[i:])
+	c.sessions[i] = session
+}
+
+func (c *Coordinator) suspendLocked(ctx context.Context) func() {
+	logger := logging.FromContext(ctx, c.logger)
+	if c.active == nil {
+		return func() {}
+	}
+	if state, err := c.folder.State(); err != nil {
+		logger.Warn("failed to read folder position", "error", err, "session", c.active.id)
+	} else if state.Index >= 0 {
+		c.active.point = ResumePoint{Playlist: state.Playlist, Index: state.Index, Paused: state.Paused}
+	}
+	var fadeOut time.Duration
+	if !c.active.point.Paused {
+		fadeOut = c.active.options.FadeOut
+	}
+	c.active = nil
+	return c.folder.stopper(fadeOut)
+}
+
+func (c *Coordinator) resumeTopLocked(ctx context.Context) {
+	logger := logging.FromContext(ctx, c.logger)
+	for {
+		top := c.topLocked()
+		if top == nil {
+			return
+		}
+		opts, ok := top.remaining()
+		if !ok {
+			logger.Info("folder max duration elapsed while suspended", "session", top.id, "dir", top.dir)
+			c.removeLocked(top)
+			continue
+		}
+		c.applyFolderVolume(ctx, top.volume)
+		logger.Info("resuming folder", "session", top.id, "dir", top.dir, "paused", top.point.Paused)
+		if err := c.folder.Restore(top.dir, opts, top.point); err != nil {
+			logger.Error("failed to resume folder", "error", err, "session", top.id, "dir", top.dir)
+			c.removeLocked(top)
+			continue
+		}
+		c.active = top
+		return
+	}
+}
+
+func (c *Coordinator) applyFolderVolume(ctx context.Context, volume *int) {
+	if volume == nil {
+		return
+	}
+	logger := logging.FromContext(ctx, c.logger)
+	if _, muted, err := GetVolumeState(ctx); err != nil {
+		logger.Warn("failed to get current volume", "error", err)
+	} else if muted {
+		c.unmuteSpeaker(ctx, c.unmute)
+	}
+	if err := SetVolume(ctx, *volume); err != nil {
+		logger.Warn("failed to set device volume for folder", "error", err, "volume", *volume)
+	} else {
+		logger.Info("volume set for folder", "volume", *volume)
+	}
 }
 
-func (c *Coordinator) PlaySingleFile(path string, volume *int) {
+func (c *Coordinator) unmuteSpeaker(ctx context.Context, unmute bool) bool {
+	logger := logging.FromContext(ctx, c.logger)
+	if !unmute {
+		logger.Info("speaker muted, keeping it muted for playback")
+		return false
+	}
+	if err := SetMute(ctx, false); err != nil {
+		logger.Warn("failed to unmute speaker", "error", err)
+		return false
+	}
+	logger.Info("speaker unmuted for playback")
+	return true
+}
+
+func (c *Coordinator) overrideVolume(ctx context.Context, unmute bool) (*volumeOverride, error) {
+	snapshot, err := snapshotVolume(ctx)
+	if err != nil {
+		return nil, err
+	}
+	override := &volumeOverride{snapshot: snapshot}
+	if snapshot.muted {
+		override.unmuted = c.unmuteSpeaker(ctx, unmute)
+	}
+	return override, nil
+}
+
+func (c *Coordinator) restoreVolume(ctx context.Context, override *volumeOverride) {
+	logger := logging.FromContext(ctx, c.logger)
+	original := override.snapshot.volume()
+	if err := override.snapshot.restore(ctx); err != nil {
+		logger.Warn("failed to restore original volume", "error", err, "volume", original)
+	} else {
+		logger.Info("volume restored", "volume", original)
+	}
+	if !override.unmuted {
+		return
+	}
+	if err := SetMute(ctx, true); err != nil {
+		logger.Warn("failed to mute speaker again", "error", err)
+	} else {
+		logger.Info("speaker muted again")
+	}
+}
+
+func (c *Coordinator) PlaySingleFile(ctx context.Context, path string, volume *int) error {
+	return c.playExclusive(ctx, path, volume, func(ctx context.Context) error {
+		return c.aplay.PlaySync(ctx, path)
+	})
+}
+
+func (c *Coordinator) PlayPCM(ctx context.Context, pcm PCM, volume *int) error {
+	return c.playExclusive(ctx, "pcm stream", volume, func(ctx context.Context) error {
+		return c.aplay.PlayPCM(ctx, pcm)
+	})
+}
+
+func (c *Coordinator) playExclusive(ctx context.Context, name string, volume *int, play func(context.Context) error) (playErr error) {
+	logger := logging.FromContext(ctx, c.logger)
+	ctx, span := tracing.Start(ctx, "playback", "file", name)
+	defer func() {
+		span.SetError(playErr)
+		span.End()
+	}()
+
+	c.playing.Add(1)
+	defer c.playing.Add(-1)
+
+	_, wait := tracing.Start(ctx, "queue wait")
 	c.mu.Lock()
-	resumeDir := ""
-	if c.folder.IsPlaying() {
-		c.logger.Info("interrupting folder for single file", "file", path)
-		c.folder.Stop()
-		resumeDir = c.resumeDir
+	suspend := func() {}
+	if c.active != nil {
+		logger.Info("interrupting folder for single file", "file", name, "session", c.active.id)
+		wait.SetAttributes("interrupted_session", c.active.id)
+		suspend = c.suspendLocked(ctx)
 	}
 	c.mu.Unlock()
+	suspend()
 
 	c.volumeMu.Lock()
 	defer c.volumeMu.Unlock()
+	wait.End()
 
-	var originalVolume int
-	restoreVolume := false
+	var override *volumeOverride
 	if volume != nil {
 		var err error
-		originalVolume, err = GetVolume()
-		if err != nil {
-			c.logger.Warn("failed to get current volume", "error", err)
+		if override, err = c.overrideVolume(ctx, c.unmute); err != nil {
+			logger.Warn("failed to get current volume", "error", err)
+		} else if err := SetVolume(ctx, *volume); err != nil {
+			logger.Warn("failed to set device volume", "error", err, "volume", *volume)
 		} else {
-			restoreVolume = true
-			if err := SetVolume(*volume); err != nil {
-				c.logger.Warn("failed to set device volume", "error", err, "volume", *volume)
-				restoreVolume = false
-			} else {
-				c.logger.Info("volume set for playback", "volume", *volume, "original", originalVolume)
-			}
+			logger.Info("volume set for playback", "volume", *volume, "original", override.snapshot.volume())
 		}
 	}
 
-	c.aplay.PlaySync(path)
+	playErr = play(ctx)
 
-	if restoreVolume {
-		if err := SetVolume(originalVolume); err != nil {
-			c.logger.Warn("failed to restore original volume", "error", err, "volume", originalVolume)
-		} else {
-			c.logger.Info("volume restored", "volume", originalVolume)
-		}
+	if override != nil {
+		c.restoreVolume(ctx, override)
 	}
 
-	if resumeDir != "" {
-		c.mu.Lock()
-		if c.resumeDir == resumeDir {
-			c.logger.Info("resuming folder", "dir", resumeDir)
-			c.folder.Start(resumeDir)
-		}
-		c.mu.Unlock()
+	c.mu.Lock()
+	if c.active == nil && c.playing.Load() == 1 {
+		c.resumeTopLocked(ctx)
 	}
+	c.mu.Unlock()
+
+	return playErr
 }
 
-func (c *Coordinator) PlayFolder(dirPath string, volume *int) error {
+func (c *Coordinator) PlayFolder(ctx context.Context, id, dirPath string, priority int, opts FolderOptions, volume *int) (string, error) {
+	logger := logging.FromContext(ctx, c.logger)
 	c.volumeMu.Lock()
 	defer c.volumeMu.Unlock()
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.folder.IsPlaying() && c.resumeDir == dirPath {
-		c.logger.Info("folder already playing, skipping restart", "dir", dirPath)
-		return nil
+	existing := c.findLocked(id)
+	if existing != nil && existing == c.active && existing.dir == dirPath && existing.options == opts && existing.priority == priority {
+		logger.Info("folder already playing, skipping restart", "session", id, "dir", dirPath)
+		return SessionPlaying, nil
 	}
 
-	if volume != nil {
-		if err := SetVolume(*volume); err != nil {
-			c.logger.Warn("failed to set device volume for folder", "error", err, "volume", *volume)
-		} else {
-			c.logger.Info("volume set for folder", "volume", *volume)
+	session := &folderSession{id: id, dir: dirPath, priority: priority, options: opts, volume: volume}
+	if opts.MaxDuration > 0 {
+		session.deadline = time.Now().Add(opts.MaxDuration)
+	}
+
+	if existing != nil {
+		if existing == c.active {
+			c.folder.Stop()
+			c.active = nil
 		}
+		c.removeLocked(existing)
 	}
+	c.pushLocked(session)
 
-	c.folder.Stop()
-	c.resumeDir = dirPath
-	return c.folder.Start(dirPath)
+	if c.topLocked() != session {
+		logger.Info("folder queued behind higher priority session", "session", id, "priority", priority, "active", c.topLocked().id)
+		return SessionQueued, nil
+	}
+
+	if c.active != nil {
+		logger.Info("folder preempted", "session", c.active.id, "by", id)
+		suspend := c.suspendLocked(ctx)
+		c.mu.Unlock()
+		suspend()
+		c.mu.Lock()
+		if c.topLocked() != session {
+			logger.Info("folder stopped while preempting", "session", id)
+			c.resumeTopLocked(ctx)
+			return "", ErrSessionNotFound
+		}
+	}
+
+	c.applyFolderVolume(ctx, volume)
+	_, span := tracing.Start(ctx, "mpv start", "dir", dirPath, "session", id)
+	err := c.folder.Start(dirPath, opts)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		c.removeLocked(session)
+		c.resumeTopLocked(ctx)
+		return "", err
+	}
+	c.active = session
+	return SessionPlaying, nil
 }
 
 func (c *Coordinator) StopFolder() {
@@ -103,11 +380,220 @@ func (c *Coordinator) StopFolder() {
 	defer c.mu.Unlock()
 
 	c.folder.Stop()
-	c.resumeDir = ""
+	c.active = nil
+	c.sessions = nil
+}
+
+func (c *Coordinator) StopSession(id string) error {
+	c.mu.Lock()
+
+	session := c.findLocked(id)
+	if session == nil {
+		c.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	c.removeLocked(session)
+	if session != c.active {
+		c.mu.Unlock()
+		return nil
+	}
+	c.folder.Stop()
+	c.active = nil
+	c.mu.Unlock()
+
+	c.resumeIdle(context.Background())
+	return nil
+}
+
+func (c *Coordinator) sessionLocked(s *folderSession) FolderSession {
+	info := FolderSession{
+		ID:       s.id,
+		Dir:      s.dir,
+		Priority: s.priority,
+		State:    SessionQueued,
+	}
+	switch {
+	case s == c.active:
+		info.State = SessionPlaying
+		if state, err := c.folder.State(); err != nil {
+			c.logger.Warn("failed to read folder state", "error", err, "session", s.id)
+		} else {
+			if state.Paused {
+				info.State = SessionPaused
+			}
+			info.Track, info.Position, info.Duration = state.Track, state.Position, state.Duration
+		}
+	case s == c.topLocked():
+		info.State = SessionInterrupted
+	}
+	if s != c.active && s.point.Index >= 0 && s.point.Index < len(s.point.Playlist) {
+		info.Track = s.point.Playlist[s.point.Index]
+	}
+	return info
+}
+
+func (c *Coordinator) Sessions() []FolderSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessions := make([]FolderSession, 0, len(c.sessions))
+	for i := len(c.sessions) - 1; i >= 0; i-- {
+		sessions = append(sessions, c.sessionLocked(c.sessions[i]))
+	}
+	return sessions
+}
+
+func (c *Coordinator) Session(id string) (FolderSession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session := c.findLocked(id)
+	if session == nil {
+		return FolderSession{}, ErrSessionNotFound
+	}
+	return c.sessionLocked(session), nil
+}
+
+func (c *Coordinator) controlFolder(fn func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.active == nil || !c.folder.IsPlaying() {
+		return ErrFolderNotPlaying
+	}
+	return fn()
+}
+
+func (c *Coordinator) NextTrack() error {
+	return c.controlFolder(c.folder.Next)
+}
+
+func (c *Coordinator) PrevTrack() error {
+	return c.controlFolder(c.folder.Prev)
 }
 
-func (c *Coordinator) PlayAsync(filepath string, volume *int) error {
-	go c.PlaySingleFile(filepath, volume)
+func (c *Coordinator) SeekFolder(seconds float64, relative bool) error {
+	return c.controlFolder(func() error {
+		return c.folder.Seek(seconds, relative)
+	})
+}
+
+func (c *Coordinator) PauseFolder() error {
+	return c.setFolderPaused(true)
+}
+
+func (c *Coordinator) ResumeFolder() error {
+	return c.setFolderPaused(false)
+}
+
+func (c *Coordinator) setFolderPaused(paused bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	top := c.topLocked()
+	if top == nil {
+		return ErrFolderNotPlaying
+	}
+	if top == c.active && c.folder.IsPlaying() {
+		set := c.folder.Resume
+		if paused {
+			set = c.folder.Pause
+		}
+		if err := set(); err != nil {
+			return err
+		}
+	}
+	top.point.Paused = paused
+	return nil
+}
+
+func (c *Coordinator) FolderState() (FolderState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	top := c.topLocked()
+	if top == nil {
+		return FolderState{}, ErrFolderNotPlaying
+	}
+	if top != c.active || !c.folder.IsPlaying() {
+		return FolderState{Dir: top.dir, Index: -1, Paused: top.point.Paused, Playlist: []string{}}, nil
+	}
+	return c.folder.State()
+}
+
+func (c *Coordinator) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := Status{
+		Playing:       c.playing.Load() > 0,
+		FolderPlaying: c.folder.IsPlaying(),
+	}
+	if top := c.topLocked(); top != nil {
+		status.Folder = top.dir
+		status.Session = top.id
+		status.QueuedFolders = len(c.sessions) - 1
+	}
+	return status
+}
+
+func (c *Coordinator) PlayAsync(ctx context.Context, filepath string, volume *int, done func(error)) error {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		err := c.PlaySingleFile(ctx, filepath, volume)
+		if done != nil {
+			done(err)
+		}
+	}()
+	return nil
+}
+
+func (c *Coordinator) PlayPCMAsync(ctx context.Context, pcm PCM, volume *int, done func(error)) error {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		err := c.PlayPCM(ctx, pcm, volume)
+		if done != nil {
+			done(err)
+		}
+	}()
+	return nil
+}
+
+func (c *Coordinator) PlayCalibration(ctx context.Context, tone PCM, calibration Calibration, volumes []int, done func(error)) error {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		err := c.playExclusive(ctx, "calibration tone", nil, func(ctx context.Context) error {
+			return c.playCalibrationSteps(ctx, tone, calibration, volumes)
+		})
+		if done != nil {
+			done(err)
+		}
+	}()
+	return nil
+}
+
+func (c *Coordinator) playCalibrationSteps(ctx context.Context, tone PCM, calibration Calibration, volumes []int) error {
+	logger := logging.FromContext(ctx, c.logger)
+	override, err := c.overrideVolume(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer c.restoreVolume(ctx, override)
+
+	for _, volume := range volumes {
+		percent := calibration.Apply(volume)
+		channels := scaleChannels(override.snapshot.channels, percent)
+		if channels == nil {
+			channels = []int{percent}
+		}
+		if err := writeVolume(ctx, calibration, channels); err != nil {
+			return err
+		}
+		logger.Info("calibration step", "volume", volume, "percent", percent, "curve", calibration.Curve)
+		if err := c.aplay.PlayPCM(ctx, tone); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,160 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	decodeTimeout     = 2 * time.Minute
+	maxDecodeDuration = 10 * time.Minute
+	maxDecodedSize    = 256 << 20
+)
+
+var ErrUnrecognizedAudio = errors.New("unrecognized audio data")
+
+type cachedDuration struct {
+	modTime  time.Time
+	size     int64
+	duration time.Duration
+}
+
+var (
+	durationMu    sync.Mutex
+	durationCache = map[string]cachedDuration{}
+)
+
+func IsWav(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".wav")
+}
+
+func isWavData(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+func isCompressedAudio(data []byte) bool {
+	switch {
+	case bytes.HasPrefix(data, []byte("fLaC")),
+		bytes.HasPrefix(data, []byte("OggS")),
+		bytes.HasPrefix(data, []byte("ID3")):
+		return true
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return true
+	}
+	return false
+}
+
+func Decode(data []byte) (PCM, error) {
+	if isWavData(data) {
+		info, pcm, err := DecodeWav(data)
+		if err != nil {
+			return PCM{}, err
+		}
+		return PCM{Data: pcm, SampleRate: info.SampleRate, Channels: info.Channels}, nil
+	}
+	if !isCompressedAudio(data) {
+		return PCM{}, fmt.Errorf("%w: expected WAV, FLAC, Ogg or MP3", ErrUnrecognizedAudio)
+	}
+
+	tmp, err := os.CreateTemp("", "jacadi-clip-")
+	if err != nil {
+		return PCM{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return PCM{}, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return PCM{}, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return decodeWithMpv(tmp.Name())
+}
+
+func DecodeFile(path string) (PCM, error) {
+	if IsWav(path) {
+		return ReadWav(path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return PCM{}, err
+	}
+	return decodeWithMpv(path)
+}
+
+func FileDuration(path string) (time.Duration, error) {
+	if IsWav(path) {
+		info, err := ReadWavInfo(path)
+		return info.Duration, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	durationMu.Lock()
+	cached, ok := durationCache[path]
+	durationMu.Unlock()
+	if ok && cached.modTime.Equal(stat.ModTime()) && cached.size == stat.Size() {
+		return cached.duration, nil
+	}
+
+	pcm, err := decodeWithMpv(path)
+	if err != nil {
+		return 0, err
+	}
+	durationMu.Lock()
+	durationCache[path] = cachedDuration{modTime: stat.ModTime(), size: stat.Size(), duration: pcm.Duration()}
+	durationMu.Unlock()
+	return pcm.Duration(), nil
+}
+
+func decodeWithMpv(path string) (PCM, error) {
+	out, err := os.CreateTemp("", "jacadi-decode-*.wav")
+	if err != nil {
+		return PCM{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), decodeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "mpv",
+		"--no-config",
+		"--no-video",
+		"--really-quiet",
+		"--untimed",
+		"--ytdl=no",
+		"--load-unsafe-playlists=no",
+		fmt.Sprintf("--length=%d", int(maxDecodeDuration.Seconds())),
+		"--ao=pcm",
+		"--ao-pcm-file="+out.Name(),
+		"--ao-pcm-waveheader=yes",
+		"--audio-format=s16",
+		path,
+	)
+	cmd.Env = append(os.Environ(), "FC_CACHEDIR=/tmp")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return PCM{}, fmt.Errorf("%w: decoding %s took longer than %s", ErrUnsupportedFormat, filepath.Base(path), decodeTimeout)
+		}
+		return PCM{}, fmt.Errorf("%w: mpv could not decode %s: %v, output: %s", ErrUnsupportedFormat, filepath.Base(path), err, string(output))
+	}
+	if stat, err := os.Stat(out.Name()); err == nil && stat.Size() > maxDecodedSize {
+		return PCM{}, fmt.Errorf("%w: %s decodes to more than %d bytes", ErrUnsupportedFormat, filepath.Base(path), maxDecodedSize)
+	}
+
+	pcm, err := ReadWav(out.Name())
+	if err != nil {
+		return PCM{}, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	return pcm, nil
+}
@@ -0,0 +1,37 @@
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsCompressedAudio(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), true},
+		{"ogg", []byte("OggS\x00\x02"), true},
+		{"mp3 with id3", []byte("ID3\x04\x00"), true},
+		{"mp3 frame", []byte{0xFF, 0xFB, 0x90, 0x64}, true},
+		{"m3u playlist", []byte("#EXTM3U\nhttp://example.com/stream\n"), false},
+		{"pls playlist", []byte("[playlist]\nFile1=/etc/passwd\n"), false},
+		{"plain path", []byte("/etc/passwd"), false},
+		{"single byte", []byte{0xFF}, false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := isCompressedAudio(tt.data); got != tt.want {
+			t.Errorf("isCompressedAudio(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeRejectsUnknownData(t *testing.T) {
+	_, err := Decode([]byte("#EXTM3U\nhttp://example.com/stream\n"))
+	if !errors.Is(err, ErrUnrecognizedAudio) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrUnrecognizedAudio)
+	}
+}
@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+var (
+	ErrUnsupportedFormat  = errors.New("unsupported audio format")
+	ErrEncoderUnavailable = errors.New("audio encoder unavailable")
+)
+
+type encoding struct {
+	contentType string
+	args        []string
+}
+
+var encodings = map[string]encoding{
+	"wav": {contentType: "audio/wav"},
+	"ogg": {contentType: "audio/ogg", args: []string{"-c:a", "libopus", "-f", "ogg"}},
+	"mp3": {contentType: "audio/mpeg", args: []string{"-c:a", "libmp3lame", "-f", "mp3"}},
+}
+
+func ContentType(format string) (string, error) {
+	enc, ok := encodings[format]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return enc.contentType, nil
+}
+
+func Encode(wav []byte, format string) ([]byte, error) {
+	enc, ok := encodings[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	if enc.args == nil {
+		return wav, nil
+	}
+
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s needs ffmpeg", ErrEncoderUnavailable, format)
+	}
+
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-f", "wav", "-i", "pipe:0"}, enc.args...)
+	cmd := exec.Command(ffmpeg, append(args, "pipe:1")...)
+	cmd.Stdin = bytes.NewReader(wav)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w, stderr: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
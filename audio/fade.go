@@ -0,0 +1,285 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	fadeStep         = 50 * time.Millisecond
+	trackFadePoll    = 100 * time.Millisecond
+	tailStartTimeout = time.Second
+	fullVolume       = 100.0
+	silentVolume     = 0.0
+	volumeProperty   = "volume"
+)
+
+func (p *FolderPlayer) running(cmd *exec.Cmd) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cmd == cmd
+}
+
+func (p *FolderPlayer) ramp(cmd *exec.Cmd, from, to float64, d time.Duration) {
+	gen := p.fadeGen.Add(1)
+	steps := max(int(d/fadeStep), 1)
+	for i := 1; i <= steps; i++ {
+		if p.fadeGen.Load() != gen || !p.running(cmd) {
+			return
+		}
+		volume := from + (to-from)*float64(i)/float64(steps)
+		if _, err := p.ipc.command("set_property", volumeProperty, volume); err != nil {
+			p.logger.Warn("volume ramp failed", "error", err)
+			return
+		}
+		if i < steps {
+			time.Sleep(fadeStep)
+		}
+	}
+}
+
+func (p *FolderPlayer) stopper(fadeOut time.Duration) func() {
+	p.mu.Lock()
+	cmd := p.cmd
+	p.mu.Unlock()
+	return func() {
+		p.fadeOut(cmd, fadeOut)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.cmd == cmd {
+			p.killLocked()
+		}
+	}
+}
+
+func (p *FolderPlayer) fadeOut(cmd *exec.Cmd, d time.Duration) {
+	if cmd == nil || d <= 0 || !p.running(cmd) {
+		return
+	}
+
+	p.fadingOut.Store(true)
+	defer p.fadingOut.Store(false)
+
+	volume := fullVolume
+	if err := p.ipc.property(volumeProperty, &volume); err != nil {
+		p.logger.Warn("failed to read folder volume", "error", err)
+	}
+	p.logger.Info("folder fading out", "duration", d)
+	p.ramp(cmd, volume, silentVolume, d)
+}
+
+func (p *FolderPlayer) fadeIn(cmd *exec.Cmd, d time.Duration) {
+	p.logger.Info("folder fading in", "duration", d)
+	p.ramp(cmd, silentVolume, fullVolume, d)
+}
+
+func (p *FolderPlayer) watchTrackFade(cmd *exec.Cmd, d time.Duration) {
+	ticker := time.NewTicker(trackFadePoll)
+	defer ticker.Stop()
+
+	track := -1
+	armed := true
+	fading := false
+	for range ticker.C {
+		if !p.running(cmd) {
+			return
+		}
+		if p.fadingOut.Load() {
+			continue
+		}
+
+		var pos int
+		var remaining float64
+		if err := p.ipc.property("playlist-pos", &pos); err != nil {
+			continue
+		}
+		if err := p.ipc.property("time-remaining", &remaining); err != nil {
+			continue
+		}
+		left := time.Duration(remaining * float64(time.Second))
+
+		volume := fullVolume
+		if pos != track || left > d {
+			track = pos
+			armed = true
+			if fading {
+				fading = false
+				p.ipc.property(volumeProperty, &volume)
+				go p.ramp(cmd, volume, fullVolume, d)
+			}
+		}
+		if armed && left > 0 && left <= d {
+			armed = false
+			if !p.crossfade(cmd, left) {
+				fading = true
+				p.ipc.property(volumeProperty, &volume)
+				go p.ramp(cmd, volume, silentVolume, left)
+			}
+		}
+	}
+}
+
+func (p *FolderPlayer) hasNextTrack() bool {
+	var pos, count int
+	var loop any
+	if p.ipc.property("playlist-pos", &pos) != nil || p.ipc.property("playlist-count", &count) != nil {
+		return false
+	}
+	if pos+1 < count {
+		return true
+	}
+	if err := p.ipc.property("loop-playlist", &loop); err != nil {
+		return false
+	}
+	return loop != false && loop != "no"
+}
+
+func (p *FolderPlayer) crossfade(cmd *exec.Cmd, d time.Duration) bool {
+	if !p.hasNextTrack() {
+		return false
+	}
+	var path string
+	var position float64
+	volume := fullVolume
+	if p.ipc.property("path", &path) != nil || p.ipc.property("time-pos", &position) != nil {
+		return false
+	}
+	p.ipc.property(volumeProperty, &volume)
+
+	tail, err := p.startTail(cmd, path, position)
+	if err != nil {
+		p.logger.Warn("track crossfade unavailable, fading through silence", "error", err)
+		return false
+	}
+
+	p.ipc.property("time-pos", &position)
+	for _, command := range [][]any{
+		{"seek", position, "absolute"},
+		{"set_property", volumeProperty, volume},
+		{"set_property", "pause", false},
+	} {
+		if _, err := p.tailIPC.command(command...); err != nil {
+			p.logger.Warn("track crossfade failed", "error", err)
+			p.stopTail(tail)
+			return false
+		}
+	}
+	if _, err := p.ipc.command("set_property", volumeProperty, silentVolume); err != nil {
+		p.stopTail(tail)
+		return false
+	}
+	if _, err := p.ipc.command("playlist-next", "force"); err != nil {
+		p.logger.Warn("track crossfade failed", "error", err)
+		p.ipc.command("set_property", volumeProperty, volume)
+		p.stopTail(tail)
+		return false
+	}
+
+	p.logger.Info("folder crossfading", "track", path, "duration", d)
+	go p.fadeTail(tail, volume, d)
+	go p.ramp(cmd, silentVolume, volume, d)
+	return true
+}
+
+func (p *FolderPlayer) startTail(cmd *exec.Cmd, path string, position float64) (*exec.Cmd, error) {
+	args := []string{
+		"--no-video",
+		"--pause",
+		"--volume=0",
+		"--loop-file=no",
+		"--input-ipc-server=" + p.tailIPC.path,
+		"--start=" + strconv.FormatFloat(position, 'f', 3, 64),
+	}
+	if dev := os.Getenv("AUDIODEV"); dev != "" {
+		args = append(args, "--audio-device=alsa/"+dev)
+	}
+	args = append(args, "--", path)
+
+	p.mu.Lock()
+	if p.cmd != cmd {
+		p.mu.Unlock()
+		return nil, ErrFolderNotPlaying
+	}
+	p.stopTailLocked()
+	os.Remove(p.tailIPC.path)
+	tail := exec.Command("mpv", args...)
+	tail.Env = append(os.Environ(), "FC_CACHEDIR=/tmp")
+	if err := tail.Start(); err != nil {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("failed to start mpv: %w", err)
+	}
+	p.tail = tail
+	p.mu.Unlock()
+
+	go func() {
+		tail.Wait()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.tail == tail {
+			p.tail = nil
+		}
+	}()
+
+	if err := p.waitTailOutput(tail); err != nil {
+		p.stopTail(tail)
+		return nil, err
+	}
+	return tail, nil
+}
+
+func (p *FolderPlayer) waitTailOutput(tail *exec.Cmd) error {
+	deadline := time.Now().Add(tailStartTimeout)
+	for {
+		if !p.tailRunning(tail) {
+			return fmt.Errorf("mpv exited before playing the end of the track")
+		}
+		var output string
+		if p.tailIPC.property("current-ao", &output) == nil && output != "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("mpv could not open the audio output for the end of the track")
+		}
+		time.Sleep(fadeStep)
+	}
+}
+
+func (p *FolderPlayer) tailRunning(tail *exec.Cmd) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tail == tail
+}
+
+func (p *FolderPlayer) fadeTail(tail *exec.Cmd, from float64, d time.Duration) {
+	defer p.stopTail(tail)
+	steps := max(int(d/fadeStep), 1)
+	for i := 1; i <= steps; i++ {
+		if !p.tailRunning(tail) {
+			return
+		}
+		volume := from * float64(steps-i) / float64(steps)
+		if _, err := p.tailIPC.command("set_property", volumeProperty, volume); err != nil {
+			return
+		}
+		time.Sleep(fadeStep)
+	}
+}
+
+func (p *FolderPlayer) stopTail(tail *exec.Cmd) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tail == tail {
+		p.stopTailLocked()
+	}
+}
+
+func (p *FolderPlayer) stopTailLocked() {
+	if p.tail == nil || p.tail.Process == nil {
+		return
+	}
+	p.tail.Process.Signal(os.Interrupt)
+	p.tail = nil
+}
@@ -2,27 +2,141 @@ package audio
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const (
+	FolderModeLoop   = "loop"
+	FolderModeOnce   = "once"
+	FolderModeRepeat = "repeat"
+)
+
+type FolderOptions struct {
+	Mode        string
+	Repeat      int
+	Shuffle     bool
+	Playlist    string
+	Glob        string
+	MaxDuration time.Duration
+	FadeIn      time.Duration
+	FadeOut     time.Duration
+	TrackFade   time.Duration
+}
+
+func (o FolderOptions) fades() bool {
+	return o.FadeIn > 0 || o.FadeOut > 0 || o.TrackFade > 0
+}
+
+var ErrFolderNotPlaying = errors.New("no folder is playing")
+
+type FolderState struct {
+	Dir      string   `json:"dir"`
+	Track    string   `json:"track,omitempty"`
+	Index    int      `json:"index"`
+	Position float64  `json:"position"`
+	Duration float64  `json:"duration"`
+	Paused   bool     `json:"paused"`
+	Playlist []string `json:"playlist"`
+}
+
+type ResumePoint struct {
+	Playlist []string
+	Index    int
+	Paused   bool
+}
+
 type FolderPlayer struct {
-	mu      sync.Mutex
-	cmd     *exec.Cmd
-	done    chan struct{}
-	logger  *slog.Logger
-	closing bool
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	dir      string
+	done     chan struct{}
+	timer    *time.Timer
+	onFinish func(dir string)
+	ipc      *mpvIPC
+	tail     *exec.Cmd
+	tailIPC  *mpvIPC
+	logger   *slog.Logger
+	closing  bool
+
+	fadeGen   atomic.Int64
+	fadingOut atomic.Bool
 }
 
-func NewFolderPlayer(logger *slog.Logger) *FolderPlayer {
-	return &FolderPlayer{logger: logger}
+func NewFolderPlayer(ipcPath string, logger *slog.Logger) *FolderPlayer {
+	return &FolderPlayer{
+		ipc:     &mpvIPC{path: ipcPath},
+		tailIPC: &mpvIPC{path: ipcPath + ".tail"},
+		logger:  logger,
+	}
 }
 
-func (p *FolderPlayer) Start(dirPath string) error {
+func (p *FolderPlayer) OnFinish(fn func(dir string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onFinish = fn
+}
+
+func folderArgs(dirPath string, opts FolderOptions, point ResumePoint) ([]string, error) {
+	var args []string
+	switch opts.Mode {
+	case "", FolderModeLoop:
+		args = append(args, "--loop-playlist=inf")
+	case FolderModeOnce:
+		args = append(args, "--loop-playlist=no")
+	case FolderModeRepeat:
+		if opts.Repeat < 1 {
+			return nil, fmt.Errorf("repeat mode needs a repeat count of at least 1")
+		}
+		args = append(args, "--loop-playlist="+strconv.Itoa(opts.Repeat))
+	default:
+		return nil, fmt.Errorf("unknown folder mode %q", opts.Mode)
+	}
+
+	if len(point.Playlist) > 0 {
+		args = append(args, "--playlist-start="+strconv.Itoa(max(point.Index, 0)), "--")
+		return append(args, point.Playlist...), nil
+	}
+
+	if opts.Shuffle {
+		args = append(args, "--shuffle")
+	}
+
+	switch {
+	case opts.Playlist != "":
+		args = append(args, "--playlist="+opts.Playlist)
+	case opts.Glob != "":
+		files, err := filepath.Glob(filepath.Join(dirPath, opts.Glob))
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", opts.Glob, err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no file in %s matches %q", dirPath, opts.Glob)
+		}
+		sort.Strings(files)
+		args = append(args, "--")
+		args = append(args, files...)
+	default:
+		args = append(args, dirPath)
+	}
+	return args, nil
+}
+
+func (p *FolderPlayer) Start(dirPath string, opts FolderOptions) error {
+	return p.Restore(dirPath, opts, ResumePoint{})
+}
+
+func (p *FolderPlayer) Restore(dirPath string, opts FolderOptions, point ResumePoint) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -32,19 +146,34 @@ func (p *FolderPlayer) Start(dirPath string) error {
 
 	p.killLocked()
 
+	sourceArgs, err := folderArgs(dirPath, opts, point)
+	if err != nil {
+		return err
+	}
+
 	args := []string{
 		"--no-video",
 		"--save-position-on-quit",
 		"--watch-later-directory=/tmp/.watchlater",
-		"--loop-playlist=inf",
+		"--input-ipc-server=" + p.ipc.path,
+	}
+	if point.Paused {
+		args = append(args, "--pause")
+	}
+	if opts.fades() {
+		args = append(args, "--watch-later-options-remove=volume")
+	}
+	if opts.FadeIn > 0 {
+		args = append(args, "--volume=0")
 	}
 
 	if dev := os.Getenv("AUDIODEV"); dev != "" {
 		args = append(args, "--audio-device=alsa/"+dev)
 	}
 
-	args = append(args, dirPath)
+	args = append(args, sourceArgs...)
 
+	os.Remove(p.ipc.path)
 	cmd := exec.Command("mpv", args...)
 	cmd.Env = append(os.Environ(), "FC_CACHEDIR=/tmp")
 
@@ -66,31 +195,131 @@ func (p *FolderPlayer) Start(dirPath string) error {
 
 	done := make(chan struct{})
 	p.cmd = cmd
+	p.dir = dirPath
 	p.done = done
-	p.logger.Info("folder started", "dir", dirPath, "pid", cmd.Process.Pid)
+	p.logger.Info("folder started",
+		"dir", dirPath,
+		"pid", cmd.Process.Pid,
+		"mode", opts.Mode,
+		"shuffle", opts.Shuffle,
+		"max_duration", opts.MaxDuration,
+	)
+
+	if opts.FadeIn > 0 {
+		go p.fadeIn(cmd, opts.FadeIn)
+	}
+	if opts.TrackFade > 0 {
+		go p.watchTrackFade(cmd, opts.TrackFade)
+	}
+
+	if opts.MaxDuration > 0 {
+		p.timer = time.AfterFunc(opts.MaxDuration, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.cmd != cmd {
+				return
+			}
+			p.logger.Info("folder reached max duration", "dir", dirPath, "max_duration", opts.MaxDuration)
+			p.killLocked()
+			p.finishedLocked(dirPath)
+		})
+	}
 
 	go func() {
 		err := cmd.Wait()
 		close(done)
 		p.mu.Lock()
 		defer p.mu.Unlock()
-		if p.cmd == cmd {
-			p.cmd = nil
+		if p.cmd != cmd {
+			return
 		}
+		p.cmd = nil
+		p.stopTimerLocked()
 		if err != nil && !p.closing {
-			p.logger.Warn("mpv exited", "error", err)
+			p.logger.Warn("mpv exited", "error", err, "dir", dirPath)
+		} else {
+			p.logger.Info("folder finished", "dir", dirPath)
 		}
+		p.finishedLocked(dirPath)
 	}()
 
 	return nil
 }
 
+func (p *FolderPlayer) finishedLocked(dir string) {
+	if p.onFinish != nil && !p.closing {
+		go p.onFinish(dir)
+	}
+}
+
 func (p *FolderPlayer) Stop() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.killLocked()
 }
 
+func (p *FolderPlayer) control(args ...any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return ErrFolderNotPlaying
+	}
+	_, err := p.ipc.command(args...)
+	return err
+}
+
+func (p *FolderPlayer) Next() error {
+	return p.control("playlist-next", "force")
+}
+
+func (p *FolderPlayer) Prev() error {
+	return p.control("playlist-prev", "force")
+}
+
+func (p *FolderPlayer) Pause() error {
+	return p.control("set_property", "pause", true)
+}
+
+func (p *FolderPlayer) Resume() error {
+	return p.control("set_property", "pause", false)
+}
+
+func (p *FolderPlayer) Seek(seconds float64, relative bool) error {
+	mode := "absolute"
+	if relative {
+		mode = "relative"
+	}
+	return p.control("seek", seconds, mode)
+}
+
+func (p *FolderPlayer) State() (FolderState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return FolderState{}, ErrFolderNotPlaying
+	}
+
+	state := FolderState{Dir: p.dir, Index: -1}
+	var playlist []mpvPlaylistEntry
+	if err := p.ipc.property("playlist", &playlist); err != nil {
+		return FolderState{}, err
+	}
+	state.Playlist = make([]string, len(playlist))
+	for i, entry := range playlist {
+		state.Playlist[i] = entry.Filename
+		if entry.Current {
+			state.Index = i
+			state.Track = entry.Filename
+		}
+	}
+	if err := p.ipc.property("pause", &state.Paused); err != nil {
+		return FolderState{}, err
+	}
+	p.ipc.property("time-pos", &state.Position)
+	p.ipc.property("duration", &state.Duration)
+	return state, nil
+}
+
 func (p *FolderPlayer) IsPlaying() bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -113,12 +342,22 @@ func (p *FolderPlayer) logPipe(pipe io.ReadCloser, stream string) {
 	}()
 }
 
+func (p *FolderPlayer) stopTimerLocked() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
 func (p *FolderPlayer) killLocked() {
+	p.stopTimerLocked()
+	p.stopTailLocked()
 	if p.cmd == nil || p.cmd.Process == nil {
 		return
 	}
 	p.logger.Info("killing mpv", "pid", p.cmd.Process.Pid)
 	p.cmd.Process.Signal(os.Interrupt)
+	p.cmd = nil
 	done := p.done
 	p.mu.Unlock()
 	<-done
@@ -0,0 +1,354 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"jacadi/tracing"
+)
+
+const asoundCardsPath = "/proc/asound/cards"
+
+var (
+	ErrMixerNotFound    = errors.New("mixer control not found")
+	ErrMixerUnsupported = errors.New("mixer control does not support this operation")
+
+	cardRe     = regexp.MustCompile(`^\s*(\d+)\s+\[(.+?)\s*\]:\s*(.*)$`)
+	controlRe  = regexp.MustCompile(`^Simple mixer control '(.*)',(\d+)$`)
+	limitsRe   = regexp.MustCompile(`(Playback|Capture)?\s*(-?\d+)\s*-\s*(-?\d+)`)
+	channelRe  = regexp.MustCompile(`^(?:(-?\d+)\s*)?((?:\[[^\]]*\]\s*)*)$`)
+	bracketRe  = regexp.MustCompile(`\[([^\]]*)\]`)
+	notFoundRe = regexp.MustCompile(`Unable to find simple control|Invalid card|No such file or directory|Cannot find the given element`)
+)
+
+type MixerCard struct {
+	Index    int            `json:"index"`
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Controls []MixerControl `json:"controls"`
+}
+
+type MixerControl struct {
+	Name         string         `json:"name"`
+	Index        int            `json:"index"`
+	Capabilities []string       `json:"capabilities"`
+	Direction    string         `json:"direction,omitempty"`
+	Min          int            `json:"min"`
+	Max          int            `json:"max"`
+	Volume       int            `json:"volume"`
+	Muted        bool           `json:"muted"`
+	Balance      float64        `json:"balance"`
+	Channels     []MixerChannel `json:"channels,omitempty"`
+	Calibration  *Calibration   `json:"calibration,omitempty"`
+}
+
+type MixerChannel struct {
+	Name    string   `json:"name"`
+	Value   *int     `json:"value,omitempty"`
+	Percent *int     `json:"percent,omitempty"`
+	DB      *float64 `json:"db,omitempty"`
+	On      *bool    `json:"on,omitempty"`
+}
+
+func (c MixerControl) HasVolume() bool {
+	for _, capability := range c.Capabilities {
+		switch capability {
+		case "volume", "pvolume", "cvolume":
+			return true
+		}
+	}
+	return false
+}
+
+func (c MixerControl) HasSwitch() bool {
+	for _, capability := range c.Capabilities {
+		switch capability {
+		case "switch", "pswitch", "cswitch":
+			return true
+		}
+	}
+	return false
+}
+
+func (c *MixerControl) summarize() {
+	var switches, off int
+	for _, channel := range c.Channels {
+		if channel.Percent != nil {
+			c.Volume = max(c.Volume, *channel.Percent)
+		}
+		if channel.On != nil {
+			switches++
+			if !*channel.On {
+				off++
+			}
+		}
+	}
+	c.Muted = switches > 0 && off == switches
+
+	if len(c.Channels) >= 2 && c.Channels[0].Percent != nil && c.Channels[1].Percent != nil {
+		left, right := float64(*c.Channels[0].Percent), float64(*c.Channels[1].Percent)
+		if level := math.Max(left, right); level > 0 {
+			c.Balance = math.Round((right-left)/level*100) / 100
+		}
+	}
+}
+
+func (c MixerControl) ref() string {
+	if c.Index == 0 {
+		return c.Name
+	}
+	return fmt.Sprintf("%s,%d", c.Name, c.Index)
+}
+
+func ParseCards(data string) []MixerCard {
+	var cards []MixerCard
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		matches := cardRe.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(matches[1])
+		name := matches[3]
+		if _, long, ok := strings.Cut(name, " - "); ok {
+			name = long
+		}
+		cards = append(cards, MixerCard{Index: index, ID: matches[2], Name: strings.TrimSpace(name)})
+	}
+	return cards
+}
+
+func ParseControls(output string) []MixerControl {
+	var controls []MixerControl
+	var current *MixerControl
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := controlRe.FindStringSubmatch(line); matches != nil {
+			if current != nil {
+				current.summarize()
+				controls = append(controls, *current)
+			}
+			index, _ := strconv.Atoi(matches[2])
+			current = &MixerControl{Name: matches[1], Index: index}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "Capabilities":
+			current.Capabilities = strings.Fields(value)
+		case key == "Limits":
+			if matches := limitsRe.FindStringSubmatch(value); matches != nil {
+				current.Min, _ = strconv.Atoi(matches[2])
+				current.Max, _ = strconv.Atoi(matches[3])
+			}
+		case key == "Playback channels", key == "Capture channels", key == "Items", strings.HasPrefix(key, "Item"):
+		case value != "":
+			if channel, direction, ok := parseChannel(key, value); ok {
+				current.Channels = append(current.Channels, channel)
+				if current.Direction == "" {
+					current.Direction = direction
+				}
+			}
+		}
+	}
+	if current != nil {
+		current.summarize()
+		controls = append(controls, *current)
+	}
+	return controls
+}
+
+func parseChannel(name, value string) (MixerChannel, string, bool) {
+	direction := ""
+	for _, d := range []string{"Playback", "Capture"} {
+		if rest, ok := strings.CutPrefix(value, d); ok {
+			direction = strings.ToLower(d)
+			value = strings.TrimSpace(rest)
+			break
+		}
+	}
+	if i := strings.Index(value, " Capture"); i >= 0 {
+		value = value[:i]
+	}
+
+	matches := channelRe.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return MixerChannel{}, "", false
+	}
+
+	channel := MixerChannel{Name: name}
+	if matches[1] != "" {
+		v, _ := strconv.Atoi(matches[1])
+		channel.Value = &v
+	}
+	for _, field := range bracketRe.FindAllStringSubmatch(matches[2], -1) {
+		text := field[1]
+		switch {
+		case strings.HasSuffix(text, "%"):
+			if percent, err := strconv.Atoi(strings.TrimSuffix(text, "%")); err == nil {
+				channel.Percent = &percent
+			}
+		case strings.HasSuffix(text, "dB"):
+			if db, err := strconv.ParseFloat(strings.TrimSuffix(text, "dB"), 64); err == nil && db > -9999 {
+				channel.DB = &db
+			}
+		case text == "on", text == "off":
+			on := text == "on"
+			channel.On = &on
+		}
+	}
+	if channel.Value == nil && channel.Percent == nil && channel.On == nil {
+		return MixerChannel{}, "", false
+	}
+	return channel, direction, true
+}
+
+func amixer(ctx context.Context, name string, args ...string) (string, error) {
+	_, span := tracing.Start(ctx, "amixer "+name, "amixer.args", strings.Join(args, " "))
+	defer span.End()
+
+	output, err := exec.CommandContext(ctx, "amixer", args...).CombinedOutput()
+	if err != nil {
+		if notFoundRe.Match(output) {
+			err = fmt.Errorf("%w: %s", ErrMixerNotFound, strings.TrimSpace(string(output)))
+		} else {
+			err = fmt.Errorf("amixer %s failed: %w, output: %s", name, err, string(output))
+		}
+		span.SetError(err)
+		return "", err
+	}
+	return string(output), nil
+}
+
+func DefaultMixer() (string, string) {
+	return alsaCard, alsaControl
+}
+
+func MixerCards(ctx context.Context) ([]MixerCard, error) {
+	var cards []MixerCard
+	if data, err := os.ReadFile(asoundCardsPath); err == nil {
+		cards = ParseCards(string(data))
+	}
+	if len(cards) == 0 {
+		index, _ := strconv.Atoi(alsaCard)
+		cards = []MixerCard{{Index: index, ID: alsaCard}}
+	}
+
+	for i := range cards {
+		output, err := amixer(ctx, "scontents", "-c", strconv.Itoa(cards[i].Index), "scontents")
+		if err != nil {
+			return nil, err
+		}
+		cards[i].Controls = ParseControls(output)
+		for j, control := range cards[i].Controls {
+			calibration, ok := lookupCalibration(strconv.Itoa(cards[i].Index), control.Name)
+			if !ok {
+				calibration, ok = lookupCalibration(cards[i].ID, control.Name)
+			}
+			if ok {
+				cards[i].Controls[j].Calibration = &calibration
+			}
+		}
+	}
+	return cards, nil
+}
+
+func GetMixerControl(ctx context.Context, card, control string) (MixerControl, error) {
+	return getMixerControl(ctx, card, control, false)
+}
+
+func getMixerControl(ctx context.Context, card, control string, mapped bool) (MixerControl, error) {
+	args := []string{"-c", card, "sget", control}
+	if mapped {
+		args = append([]string{"-M"}, args...)
+	}
+	output, err := amixer(ctx, "get", args...)
+	if err != nil {
+		return MixerControl{}, err
+	}
+	controls := ParseControls(output)
+	if len(controls) == 0 {
+		return MixerControl{}, fmt.Errorf("%w: %s", ErrMixerNotFound, control)
+	}
+	return controls[0], nil
+}
+
+func SetMixerPercent(ctx context.Context, card, control string, percent int) (MixerControl, error) {
+	percent = max(0, min(100, percent))
+	return setMixer(ctx, card, control, MixerControl.HasVolume, fmt.Sprintf("%d%%", percent))
+}
+
+func SetMixerDB(ctx context.Context, card, control string, db float64) (MixerControl, error) {
+	return setMixer(ctx, card, control, MixerControl.HasVolume, strconv.FormatFloat(db, 'f', 2, 64)+"dB")
+}
+
+func SetMixerMute(ctx context.Context, card, control string, muted bool) (MixerControl, error) {
+	value := "unmute"
+	if muted {
+		value = "mute"
+	}
+	return setMixer(ctx, card, control, MixerControl.HasSwitch, value)
+}
+
+func SetMixerBalance(ctx context.Context, card, control string, balance float64) (MixerControl, error) {
+	current, err := GetMixerControl(ctx, card, control)
+	if err != nil {
+		return MixerControl{}, err
+	}
+	if !current.HasVolume() || len(current.Channels) < 2 {
+		return MixerControl{}, fmt.Errorf("%w: %s is not a stereo volume control", ErrMixerUnsupported, control)
+	}
+
+	left, right := BalanceLevels(current, balance)
+	return setMixer(ctx, card, control, MixerControl.HasVolume, fmt.Sprintf("%d%%,%d%%", left, right))
+}
+
+func BalanceLevels(c MixerControl, balance float64) (int, int) {
+	balance = math.Max(-1, math.Min(1, balance))
+	left, right := float64(c.Volume), float64(c.Volume)
+	if balance > 0 {
+		left *= 1 - balance
+	} else {
+		right *= 1 + balance
+	}
+	return int(math.Round(left)), int(math.Round(right))
+}
+
+func setMixer(ctx context.Context, card, control string, supported func(MixerControl) bool, values ...string) (MixerControl, error) {
+	current, err := GetMixerControl(ctx, card, control)
+	if err != nil {
+		return MixerControl{}, err
+	}
+	if !supported(current) {
+		return MixerControl{}, fmt.Errorf("%w: %s (%s)", ErrMixerUnsupported, control, strings.Join(current.Capabilities, " "))
+	}
+
+	args := append([]string{"-c", card, "--", "sset", current.ref()}, values...)
+	output, err := amixer(ctx, "set", args...)
+	if err != nil {
+		return MixerControl{}, err
+	}
+	controls := ParseControls(output)
+	if len(controls) == 0 {
+		return GetMixerControl(ctx, card, control)
+	}
+	return controls[0], nil
+}
@@ -0,0 +1,242 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+)
+
+const usbSpeakerScontents = `Simple mixer control 'PCM',0
+  Capabilities: pvolume pvolume-joined pswitch pswitch-joined
+  Playback channels: Mono
+  Limits: Playback 0 - 37
+  Mono: Playback 30 [81%] [-7.00dB] [on]
+Simple mixer control 'Mic',0
+  Capabilities: cvolume cvolume-joined cswitch cswitch-joined
+  Capture channels: Mono
+  Limits: Capture 0 - 16
+  Mono: Capture 0 [0%] [-99999.99dB] [on]
+Simple mixer control 'Auto Gain Control',0
+  Capabilities: pswitch pswitch-joined
+  Playback channels: Mono
+  Mono: Playback [off]
+`
+
+const hdaScontents = `Simple mixer control 'Master',0
+  Capabilities: pvolume pswitch
+  Playback channels: Front Left - Front Right
+  Limits: Playback 0 - 87
+  Mono:
+  Front Left: Playback 60 [69%] [-20.25dB] [on]
+  Front Right: Playback 45 [52%] [-31.50dB] [on]
+Simple mixer control 'Capture',0
+  Capabilities: cvolume cswitch
+  Capture channels: Front Left - Front Right
+  Limits: Capture 0 - 63
+  Front Left: Capture 39 [62%] [12.00dB] [off]
+  Front Right: Capture 39 [62%] [12.00dB] [off]
+Simple mixer control 'Input Source',0
+  Capabilities: cenum
+  Items: 'Mic' 'Front Mic' 'Line'
+  Item0: 'Mic'
+Simple mixer control 'Loopback Mixing',1
+  Capabilities: enum
+  Items: 'Disabled' 'Enabled'
+  Item0: 'Disabled'
+`
+
+const asoundCards = ` 0 [PCH            ]: HDA-Intel - HDA Intel PCH
+                      HDA Intel PCH at 0xf7f10000 irq 33
+ 1 [Device         ]: USB-Audio - USB2.0 Device
+                      Generic USB2.0 Device at usb-0000:00:14.0-2, full speed
+ 2 [vc4hdmi        ]: vc4-hdmi
+                      vc4-hdmi
+`
+
+func intPtr(v int) *int { return &v }
+
+func floatPtr(v float64) *float64 { return &v }
+
+func boolPtr(v bool) *bool { return &v }
+
+func TestParseCards(t *testing.T) {
+	want := []MixerCard{
+		{Index: 0, ID: "PCH", Name: "HDA Intel PCH"},
+		{Index: 1, ID: "Device", Name: "USB2.0 Device"},
+		{Index: 2, ID: "vc4hdmi", Name: "vc4-hdmi"},
+	}
+	if got := ParseCards(asoundCards); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCards() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseControls(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []MixerControl
+	}{
+		{
+			name:   "usb speaker",
+			output: usbSpeakerScontents,
+			want: []MixerControl{
+				{
+					Name:         "PCM",
+					Capabilities: []string{"pvolume", "pvolume-joined", "pswitch", "pswitch-joined"},
+					Direction:    "playback",
+					Max:          37,
+					Volume:       81,
+					Channels: []MixerChannel{
+						{Name: "Mono", Value: intPtr(30), Percent: intPtr(81), DB: floatPtr(-7), On: boolPtr(true)},
+					},
+				},
+				{
+					Name:         "Mic",
+					Capabilities: []string{"cvolume", "cvolume-joined", "cswitch", "cswitch-joined"},
+					Direction:    "capture",
+					Max:          16,
+					Channels: []MixerChannel{
+						{Name: "Mono", Value: intPtr(0), Percent: intPtr(0), On: boolPtr(true)},
+					},
+				},
+				{
+					Name:         "Auto Gain Control",
+					Capabilities: []string{"pswitch", "pswitch-joined"},
+					Direction:    "playback",
+					Muted:        true,
+					Channels: []MixerChannel{
+						{Name: "Mono", On: boolPtr(false)},
+					},
+				},
+			},
+		},
+		{
+			name:   "hda",
+			output: hdaScontents,
+			want: []MixerControl{
+				{
+					Name:         "Master",
+					Capabilities: []string{"pvolume", "pswitch"},
+					Direction:    "playback",
+					Max:          87,
+					Volume:       69,
+					Balance:      -0.25,
+					Channels: []MixerChannel{
+						{Name: "Front Left", Value: intPtr(60), Percent: intPtr(69), DB: floatPtr(-20.25), On: boolPtr(true)},
+						{Name: "Front Right", Value: intPtr(45), Percent: intPtr(52), DB: floatPtr(-31.5), On: boolPtr(true)},
+					},
+				},
+				{
+					Name:         "Capture",
+					Capabilities: []string{"cvolume", "cswitch"},
+					Direction:    "capture",
+					Max:          63,
+					Volume:       62,
+					Muted:        true,
+					Channels: []MixerChannel{
+						{Name: "Front Left", Value: intPtr(39), Percent: intPtr(62), DB: floatPtr(12), On: boolPtr(false)},
+						{Name: "Front Right", Value: intPtr(39), Percent: intPtr(62), DB: floatPtr(12), On: boolPtr(false)},
+					},
+				},
+				{
+					Name:         "Input Source",
+					Capabilities: []string{"cenum"},
+				},
+				{
+					Name:         "Loopback Mixing",
+					Index:        1,
+					Capabilities: []string{"enum"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseControls(tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseControls() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		value     string
+		want      MixerChannel
+		direction string
+		ok        bool
+	}{
+		{
+			name:      "playback with dB",
+			key:       "Front Left",
+			value:     "Playback 60 [69%] [-20.25dB] [on]",
+			want:      MixerChannel{Name: "Front Left", Value: intPtr(60), Percent: intPtr(69), DB: floatPtr(-20.25), On: boolPtr(true)},
+			direction: "playback",
+			ok:        true,
+		},
+		{
+			name:      "muted floor sentinel",
+			key:       "Mono",
+			value:     "Capture 0 [0%] [-99999.99dB] [off]",
+			want:      MixerChannel{Name: "Mono", Value: intPtr(0), Percent: intPtr(0), On: boolPtr(false)},
+			direction: "capture",
+			ok:        true,
+		},
+		{
+			name:      "playback and capture on one line",
+			key:       "Mono",
+			value:     "Playback 31 [100%] [0.00dB] [on] Capture 0 [0%] [-34.50dB] [off]",
+			want:      MixerChannel{Name: "Mono", Value: intPtr(31), Percent: intPtr(100), DB: floatPtr(0), On: boolPtr(true)},
+			direction: "playback",
+			ok:        true,
+		},
+		{
+			name:      "switch only",
+			key:       "Mono",
+			value:     "Playback [on]",
+			want:      MixerChannel{Name: "Mono", On: boolPtr(true)},
+			direction: "playback",
+			ok:        true,
+		},
+		{
+			name:  "enum item",
+			key:   "Item0",
+			value: "'Mic'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, direction, ok := parseChannel(tt.key, tt.value)
+			if ok != tt.ok || direction != tt.direction || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseChannel() = %+v, %q, %v, want %+v, %q, %v", got, direction, ok, tt.want, tt.direction, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBalanceLevels(t *testing.T) {
+	control := MixerControl{Volume: 80}
+	tests := []struct {
+		balance     float64
+		left, right int
+	}{
+		{0, 80, 80},
+		{0.5, 40, 80},
+		{-0.25, 80, 60},
+		{1, 0, 80},
+		{-1, 80, 0},
+		{2, 0, 80},
+		{-3, 80, 0},
+	}
+
+	for _, tt := range tests {
+		left, right := BalanceLevels(control, tt.balance)
+		if left != tt.left || right != tt.right {
+			t.Errorf("BalanceLevels(%v) = %d, %d, want %d, %d", tt.balance, left, right, tt.left, tt.right)
+		}
+	}
+}
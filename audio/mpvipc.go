@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const mpvIPCTimeout = 2 * time.Second
+
+type mpvIPC struct {
+	path      string
+	requestID atomic.Int64
+}
+
+type mpvRequest struct {
+	Command   []any `json:"command"`
+	RequestID int64 `json:"request_id"`
+}
+
+type mpvResponse struct {
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error"`
+	RequestID *int64          `json:"request_id"`
+	Event     string          `json:"event"`
+}
+
+type mpvPlaylistEntry struct {
+	Filename string `json:"filename"`
+	Current  bool   `json:"current"`
+}
+
+func (m *mpvIPC) dial() (net.Conn, error) {
+	deadline := time.Now().Add(mpvIPCTimeout)
+	for {
+		conn, err := net.DialTimeout("unix", m.path, mpvIPCTimeout)
+		if err == nil {
+			conn.SetDeadline(deadline)
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to connect to mpv: %w", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (m *mpvIPC) command(args ...any) (json.RawMessage, error) {
+	conn, err := m.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	id := m.requestID.Add(1)
+	if err := json.NewEncoder(conn).Encode(mpvRequest{Command: args, RequestID: id}); err != nil {
+		return nil, fmt.Errorf("failed to send mpv command: %w", err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var resp mpvResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			return nil, fmt.Errorf("invalid mpv response: %w", err)
+		}
+		if resp.Event != "" || resp.RequestID == nil || *resp.RequestID != id {
+			continue
+		}
+		if resp.Error != "success" {
+			return nil, fmt.Errorf("mpv %v: %s", args[0], resp.Error)
+		}
+		return resp.Data, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mpv response: %w", err)
+	}
+	return nil, fmt.Errorf("mpv closed the connection")
+}
+
+func (m *mpvIPC) property(name string, value any) error {
+	data, err := m.command("get_property", name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
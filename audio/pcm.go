@@ -0,0 +1,126 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+type PCM struct {
+	Data       []byte
+	SampleRate int
+	Channels   int
+}
+
+func ReadWav(path string) (PCM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PCM{}, err
+	}
+	info, pcm, err := DecodeWav(data)
+	if err != nil {
+		return PCM{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return PCM{Data: pcm, SampleRate: info.SampleRate, Channels: info.Channels}, nil
+}
+
+func (p PCM) frames() int {
+	if p.Channels < 1 {
+		return 0
+	}
+	return len(p.Data) / (2 * p.Channels)
+}
+
+func (p PCM) Duration() time.Duration {
+	if p.SampleRate < 1 {
+		return 0
+	}
+	return time.Duration(p.frames()) * time.Second / time.Duration(p.SampleRate)
+}
+
+func (p PCM) Silence(d time.Duration) []byte {
+	frames := int(d.Seconds() * float64(p.SampleRate))
+	return make([]byte, frames*p.Channels*2)
+}
+
+func Tone(frequency float64, d time.Duration, sampleRate int) PCM {
+	frames := int(d.Seconds() * float64(sampleRate))
+	ramp := max(1, min(frames/2, sampleRate/100))
+	data := make([]byte, frames*2)
+	for i := range frames {
+		gain := 0.5
+		if i < ramp {
+			gain *= float64(i) / float64(ramp)
+		} else if frames-i < ramp {
+			gain *= float64(frames-i) / float64(ramp)
+		}
+		sample := int16(gain * math.MaxInt16 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(sample))
+	}
+	return PCM{Data: data, SampleRate: sampleRate, Channels: 1}
+}
+
+func (p PCM) Convert(sampleRate, channels int) PCM {
+	if p.SampleRate == sampleRate && p.Channels == channels {
+		return p
+	}
+
+	in := make([]int16, len(p.Data)/2)
+	for i := range in {
+		in[i] = int16(binary.LittleEndian.Uint16(p.Data[2*i:]))
+	}
+	inFrames := p.frames()
+
+	sample := func(frame, channel int) float64 {
+		if channels == p.Channels {
+			return float64(in[frame*p.Channels+channel])
+		}
+		if p.Channels == 1 {
+			return float64(in[frame])
+		}
+		var sum float64
+		for c := 0; c < p.Channels; c++ {
+			sum += float64(in[frame*p.Channels+c])
+		}
+		return sum / float64(p.Channels)
+	}
+
+	outFrames := int(int64(inFrames) * int64(sampleRate) / int64(p.SampleRate))
+	out := make([]byte, outFrames*channels*2)
+	for f := 0; f < outFrames; f++ {
+		pos := float64(f) * float64(p.SampleRate) / float64(sampleRate)
+		i := int(pos)
+		frac := pos - float64(i)
+		next := min(i+1, inFrames-1)
+		for c := 0; c < channels; c++ {
+			value := sample(i, c)*(1-frac) + sample(next, c)*frac
+			binary.LittleEndian.PutUint16(out[2*(f*channels+c):], uint16(int16(value)))
+		}
+	}
+	return PCM{Data: out, SampleRate: sampleRate, Channels: channels}
+}
+
+func Concat(clips []PCM, gaps []time.Duration) (PCM, error) {
+	if len(clips) == 0 {
+		return PCM{}, fmt.Errorf("nothing to concatenate")
+	}
+
+	result := PCM{SampleRate: clips[0].SampleRate, Channels: clips[0].Channels}
+	for _, clip := range clips[1:] {
+		result.SampleRate = max(result.SampleRate, clip.SampleRate)
+		result.Channels = max(result.Channels, clip.Channels)
+	}
+	if result.SampleRate < 1 || result.Channels < 1 {
+		return PCM{}, fmt.Errorf("invalid audio format: %d channels at %d Hz", result.Channels, result.SampleRate)
+	}
+
+	for i, clip := range clips {
+		if i > 0 && i <= len(gaps) && gaps[i-1] > 0 {
+			result.Data = append(result.Data, result.Silence(gaps[i-1])...)
+		}
+		result.Data = append(result.Data, clip.Convert(result.SampleRate, result.Channels).Data...)
+	}
+	return result, nil
+}
@@ -1,14 +1,30 @@
 package audio
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
+	"strconv"
 	"sync"
 	"sync/atomic"
+
+	"jacadi/logging"
+	"jacadi/tracing"
 )
 
+var ErrDeviceBusy = errors.New("audio device busy")
+
+func playerError(name string, err error, output []byte) error {
+	if bytes.Contains(output, []byte("Device or resource busy")) {
+		return fmt.Errorf("%s failed: %w: %w, output: %s", name, ErrDeviceBusy, err, string(output))
+	}
+	return fmt.Errorf("%s failed: %w, output: %s", name, err, string(output))
+}
+
 type AplayPlayer struct {
 	wg      sync.WaitGroup
 	logger  *slog.Logger
@@ -26,30 +42,90 @@ func NewAplayPlayer(logger *slog.Logger) (*AplayPlayer, error) {
 	}, nil
 }
 
-func (p *AplayPlayer) PlaySync(filepath string) {
+func (p *AplayPlayer) PlaySync(ctx context.Context, filepath string) error {
+	logger := logging.FromContext(ctx, p.logger)
 	p.wg.Add(1)
 	defer p.wg.Done()
 
-	p.logger.Info("audio playback started", "file", filepath)
+	logger.Info("audio playback started", "file", filepath)
 
 	var cmd *exec.Cmd
-	if dev := os.Getenv("AUDIODEV"); dev != "" {
+	dev := os.Getenv("AUDIODEV")
+	switch {
+	case !IsWav(filepath):
+		args := []string{"--no-video", "--really-quiet"}
+		if dev != "" {
+			args = append(args, "--audio-device=alsa/"+dev)
+		}
+		cmd = exec.Command("mpv", append(args, filepath)...)
+		cmd.Env = append(os.Environ(), "FC_CACHEDIR=/tmp")
+	case dev != "":
 		cmd = exec.Command("aplay", "-q", "-D", dev, filepath)
-	} else {
+	default:
 		cmd = exec.Command("aplay", "-q", filepath)
 	}
 
+	_, span := tracing.Start(ctx, cmd.Args[0], "file", filepath, "audio.device", dev)
+	defer span.End()
+
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		p.logger.Error("audio playback failed",
+		span.SetError(err)
+		logger.Error("audio playback failed",
 			"file", filepath,
 			"error", err,
 			"output", string(output),
 		)
-		return
+		return playerError(cmd.Args[0], err, output)
+	}
+
+	logger.Info("audio playback completed", "file", filepath)
+	return nil
+}
+
+func (p *AplayPlayer) PlayPCM(ctx context.Context, pcm PCM) error {
+	logger := logging.FromContext(ctx, p.logger)
+	p.wg.Add(1)
+	defer p.wg.Done()
+
+	logger.Info("audio playback started", "sample_rate", pcm.SampleRate, "channels", pcm.Channels, "duration", pcm.Duration())
+
+	args := []string{
+		"-q",
+		"-r", strconv.Itoa(pcm.SampleRate),
+		"-c", strconv.Itoa(pcm.Channels),
+		"-f", "S16_LE",
+		"-t", "raw",
+	}
+	dev := os.Getenv("AUDIODEV")
+	if dev != "" {
+		args = append(args, "-D", dev)
+	}
+	args = append(args, "-")
+
+	_, span := tracing.Start(ctx, "aplay",
+		"audio.device", dev,
+		"sample_rate", pcm.SampleRate,
+		"channels", pcm.Channels,
+		"duration_ms", pcm.Duration().Milliseconds(),
+	)
+	defer span.End()
+
+	cmd := exec.Command("aplay", args...)
+	cmd.Stdin = bytes.NewReader(pcm.Data)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		span.SetError(err)
+		logger.Error("audio playback failed",
+			"error", err,
+			"output", string(output),
+		)
+		return playerError("aplay", err, output)
 	}
 
-	p.logger.Info("audio playback completed", "file", filepath)
+	logger.Info("audio playback completed", "duration", pcm.Duration())
+	return nil
 }
 
 func (p *AplayPlayer) Close() error {
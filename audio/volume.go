@@ -1,10 +1,12 @@
 package audio
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"math"
 	"regexp"
-	"strconv"
+	"strings"
+	"sync"
 
 	"jacadi/config"
 )
@@ -12,9 +14,21 @@ import (
 var (
 	alsaCard    string
 	alsaControl string
-	volumeRe    = regexp.MustCompile(`\[(\d+)%\]`)
 )
 
+var (
+	lastVolumeMu sync.Mutex
+	lastVolume   volumeSnapshot
+)
+
+type volumeSnapshot struct {
+	percent     int
+	level       int
+	channels    []int
+	muted       bool
+	calibration Calibration
+}
+
 func init() {
 	alsaControl = config.GetEnv("ALSA_CONTROL", "PCM")
 	audiodev := config.GetEnv("AUDIODEV", "")
@@ -30,22 +44,121 @@ func init() {
 	alsaCard = "0"
 }
 
-func GetVolume() (int, error) {
-	cmd := exec.Command("amixer", "-c", alsaCard, "sget", alsaControl)
-	output, err := cmd.CombinedOutput()
+func SinkCalibration() Calibration {
+	return CalibrationFor(alsaCard, alsaControl)
+}
+
+func snapshotVolume(ctx context.Context) (volumeSnapshot, error) {
+	calibration := SinkCalibration()
+	control, err := getMixerControl(ctx, alsaCard, alsaControl, calibration.mapped())
 	if err != nil {
-		return 0, fmt.Errorf("amixer get failed: %w, output: %s", err, string(output))
+		return volumeSnapshot{}, err
+	}
+	return volumeSnapshot{
+		percent:     control.Volume,
+		level:       recallVolume(calibration, control.Volume),
+		channels:    channelPercents(control),
+		muted:       control.Muted,
+		calibration: calibration,
+	}, nil
+}
+
+func channelPercents(control MixerControl) []int {
+	var channels []int
+	for _, channel := range control.Channels {
+		if channel.Percent != nil {
+			channels = append(channels, *channel.Percent)
+		}
+	}
+	return channels
+}
+
+func scaleChannels(channels []int, percent int) []int {
+	loudest := 0
+	for _, channel := range channels {
+		loudest = max(loudest, channel)
+	}
+	if loudest == 0 {
+		return nil
+	}
+	scaled := make([]int, len(channels))
+	for i, channel := range channels {
+		scaled[i] = int(math.Round(float64(channel) * float64(percent) / float64(loudest)))
+	}
+	return scaled
+}
+
+func rememberVolume(calibration Calibration, level int, channels []int) {
+	lastVolumeMu.Lock()
+	defer lastVolumeMu.Unlock()
+	percent := 0
+	for _, channel := range channels {
+		percent = max(percent, channel)
+	}
+	lastVolume = volumeSnapshot{percent: percent, level: level, channels: channels, calibration: calibration}
+}
+
+func rememberedChannels(calibration Calibration) []int {
+	lastVolumeMu.Lock()
+	defer lastVolumeMu.Unlock()
+	if lastVolume.calibration != calibration {
+		return nil
 	}
+	return lastVolume.channels
+}
 
-	matches := volumeRe.FindStringSubmatch(string(output))
-	if len(matches) > 1 {
-		vol, _ := strconv.Atoi(matches[1])
-		return vol, nil
+func recallVolume(calibration Calibration, percent int) int {
+	lastVolumeMu.Lock()
+	defer lastVolumeMu.Unlock()
+	if lastVolume.calibration == calibration && lastVolume.percent == percent {
+		return lastVolume.level
 	}
-	return 0, nil
+	return calibration.Invert(percent)
+}
+
+func (s volumeSnapshot) volume() int {
+	return s.level
 }
 
-func SetVolume(volume int) error {
+func (s volumeSnapshot) restore(ctx context.Context) error {
+	channels := s.channels
+	if len(channels) == 0 {
+		channels = []int{s.percent}
+	}
+	if err := writeVolume(ctx, s.calibration, channels); err != nil {
+		return err
+	}
+	rememberVolume(s.calibration, s.level, channels)
+	return nil
+}
+
+func writeVolume(ctx context.Context, calibration Calibration, channels []int) error {
+	values := make([]string, len(channels))
+	for i, channel := range channels {
+		values[i] = fmt.Sprintf("%d%%", channel)
+	}
+	args := []string{"-c", alsaCard, "sset", alsaControl, strings.Join(values, ",")}
+	if calibration.mapped() {
+		args = append([]string{"-M"}, args...)
+	}
+	_, err := amixer(ctx, "set", args...)
+	return err
+}
+
+func GetVolume(ctx context.Context) (int, error) {
+	volume, _, err := GetVolumeState(ctx)
+	return volume, err
+}
+
+func GetVolumeState(ctx context.Context) (int, bool, error) {
+	snapshot, err := snapshotVolume(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return snapshot.volume(), snapshot.muted, nil
+}
+
+func SetVolume(ctx context.Context, volume int) error {
 	if volume < 0 {
 		volume = 0
 	}
@@ -53,9 +166,24 @@ func SetVolume(volume int) error {
 		volume = 100
 	}
 
-	cmd := exec.Command("amixer", "-c", alsaCard, "sset", alsaControl, fmt.Sprintf("%d%%", volume))
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("amixer set failed: %w, output: %s", err, string(output))
+	calibration := SinkCalibration()
+	percent := calibration.Apply(volume)
+	channels := []int{percent}
+	if control, err := getMixerControl(ctx, alsaCard, alsaControl, calibration.mapped()); err == nil {
+		if scaled := scaleChannels(channelPercents(control), percent); scaled != nil {
+			channels = scaled
+		} else if scaled := scaleChannels(rememberedChannels(calibration), percent); scaled != nil && len(scaled) == len(channelPercents(control)) {
+			channels = scaled
+		}
+	}
+	if err := writeVolume(ctx, calibration, channels); err != nil {
+		return err
 	}
+	rememberVolume(calibration, volume, channels)
 	return nil
 }
+
+func SetMute(ctx context.Context, muted bool) error {
+	_, err := SetMixerMute(ctx, alsaCard, alsaControl, muted)
+	return err
+}
@@ -0,0 +1,29 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScaleChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []int
+		percent  int
+		want     []int
+	}{
+		{"mono", []int{40}, 70, []int{70}},
+		{"centered", []int{60, 60}, 30, []int{30, 30}},
+		{"balanced left", []int{80, 40}, 50, []int{50, 25}},
+		{"balanced right", []int{30, 60}, 90, []int{45, 90}},
+		{"right only", []int{0, 50}, 20, []int{0, 20}},
+		{"silent", []int{0, 0}, 40, nil},
+		{"no channels", nil, 40, nil},
+	}
+
+	for _, tt := range tests {
+		if got := scaleChannels(tt.channels, tt.percent); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("scaleChannels(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
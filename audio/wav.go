@@ -0,0 +1,149 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatExtensible = 0xFFFE
+	wavMinFmtSize       = 16
+	wavMaxFmtSize       = 40
+)
+
+type WavInfo struct {
+	Format        int
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	DataSize      int64
+	Duration      time.Duration
+}
+
+func ReadWavInfo(path string) (WavInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return WavInfo{}, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return WavInfo{}, err
+	}
+	return parseWavHeader(f, stat.Size())
+}
+
+func DecodeWav(data []byte) (WavInfo, []byte, error) {
+	r := bytes.NewReader(data)
+	info, err := parseWavHeader(r, int64(len(data)))
+	if err != nil {
+		return WavInfo{}, nil, err
+	}
+	if info.Channels < 1 || info.SampleRate < 1 {
+		return WavInfo{}, nil, fmt.Errorf("invalid WAV header: %d channels at %d Hz", info.Channels, info.SampleRate)
+	}
+	if (info.Format != wavFormatPCM && info.Format != wavFormatExtensible) || info.BitsPerSample != 16 {
+		return WavInfo{}, nil, fmt.Errorf("unsupported WAV format %d with %d bits per sample, expected 16-bit PCM", info.Format, info.BitsPerSample)
+	}
+
+	offset := int64(len(data)) - int64(r.Len())
+	end := offset + info.DataSize
+	if info.DataSize <= 0 || end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	pcm := data[offset:end]
+	pcm = pcm[:len(pcm)-len(pcm)%(2*info.Channels)]
+
+	bytesPerSecond := int64(info.SampleRate * info.Channels * 2)
+	info.DataSize = int64(len(pcm))
+	info.Duration = time.Duration(info.DataSize * int64(time.Second) / bytesPerSecond)
+	return info, pcm, nil
+}
+
+func EncodeWav(pcm []byte, sampleRate, channels int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(wavFormatPCM))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*channels*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+func parseWavHeader(r io.Reader, total int64) (WavInfo, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return WavInfo{}, fmt.Errorf("failed to read RIFF header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return WavInfo{}, fmt.Errorf("not a WAV file")
+	}
+	remaining := total - int64(len(riff))
+
+	var info WavInfo
+	haveFormat := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return WavInfo{}, fmt.Errorf("failed to read chunk header: %w", err)
+		}
+		remaining -= int64(len(chunk))
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if id != "data" && size > remaining {
+			return WavInfo{}, fmt.Errorf("%q chunk of %d bytes exceeds the %d bytes left in the file", id, size, remaining)
+		}
+
+		switch id {
+		case "fmt ":
+			if size < wavMinFmtSize || size > wavMaxFmtSize {
+				return WavInfo{}, fmt.Errorf("invalid fmt chunk size %d", size)
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return WavInfo{}, fmt.Errorf("failed to read fmt chunk: %w", err)
+			}
+			remaining -= size + size%2
+			if size%2 == 1 {
+				if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+					return WavInfo{}, fmt.Errorf("failed to read fmt chunk: %w", err)
+				}
+			}
+			info.Format = int(binary.LittleEndian.Uint16(buf[0:2]))
+			info.Channels = int(binary.LittleEndian.Uint16(buf[2:4]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(buf[4:8]))
+			info.BitsPerSample = int(binary.LittleEndian.Uint16(buf[14:16]))
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return WavInfo{}, fmt.Errorf("data chunk before fmt chunk")
+			}
+			info.DataSize = min(size, remaining)
+			bytesPerSecond := int64(info.SampleRate * info.Channels * info.BitsPerSample / 8)
+			if bytesPerSecond > 0 {
+				info.Duration = time.Duration(info.DataSize * int64(time.Second) / bytesPerSecond)
+			}
+			return info, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return WavInfo{}, fmt.Errorf("failed to skip %q chunk: %w", id, err)
+			}
+			remaining -= size + size%2
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func wavChunk(id string, size uint32, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(id)
+	binary.Write(&buf, binary.LittleEndian, size)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func riffWave(chunks ...[]byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WAVE")
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+func TestDecodeWav(t *testing.T) {
+	pcm := make([]byte, 16000*2)
+	valid := EncodeWav(pcm, 16000, 1)
+	fmtBody := valid[20:36]
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantErr  bool
+		wantSize int64
+	}{
+		{name: "encoded", data: valid, wantSize: int64(len(pcm))},
+		{
+			name:     "list chunk before data",
+			data:     riffWave(wavChunk("fmt ", 16, fmtBody), wavChunk("LIST", 4, []byte("INFO")), wavChunk("data", 4, []byte{1, 2, 3, 4})),
+			wantSize: 4,
+		},
+		{
+			name:     "data size larger than file",
+			data:     riffWave(wavChunk("fmt ", 16, fmtBody), wavChunk("data", 0xFFFFFFFF, []byte{1, 2, 3, 4})),
+			wantSize: 4,
+		},
+		{name: "huge fmt chunk", data: riffWave(wavChunk("fmt ", 0xFFFFFFF0, fmtBody)), wantErr: true},
+		{name: "fmt chunk above extensible size", data: riffWave(wavChunk("fmt ", 48, make([]byte, 48))), wantErr: true},
+		{name: "short fmt chunk", data: riffWave(wavChunk("fmt ", 8, make([]byte, 8))), wantErr: true},
+		{name: "huge skipped chunk", data: riffWave(wavChunk("LIST", 0xFFFFFFF0, []byte("INFO"))), wantErr: true},
+		{name: "data before fmt", data: riffWave(wavChunk("data", 4, []byte{1, 2, 3, 4})), wantErr: true},
+		{name: "not riff", data: []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, got, err := DecodeWav(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DecodeWav() = %+v, want an error", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeWav() error = %v", err)
+			}
+			if info.DataSize != tt.wantSize || int64(len(got)) != tt.wantSize {
+				t.Errorf("DecodeWav() data size = %d (%d bytes), want %d", info.DataSize, len(got), tt.wantSize)
+			}
+		})
+	}
+
+	info, _, _ := DecodeWav(valid)
+	if info.Duration != time.Second {
+		t.Errorf("DecodeWav() duration = %s, want 1s", info.Duration)
+	}
+}
@@ -8,20 +8,65 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
 )
 
 type DeviceConfig map[string]Device
 
 type Device struct {
-	Volume   *int               `json:"volume,omitempty"`
-	Commands map[string]Command `json:"commands"`
+	Volume    *int               `json:"volume,omitempty"`
+	TTSEngine string             `json:"tts_engine,omitempty"`
+	Voice     string             `json:"voice,omitempty"`
+	Commands  map[string]Command `json:"commands"`
 }
 
+var AudioExtensions = []string{".wav", ".flac", ".ogg", ".mp3", ".opus"}
+
 type Command struct {
-	Text    string `json:"text"`
-	Type    string `json:"type,omitempty"`
-	Path    string `json:"path,omitempty"`
-	IsExtra bool   `json:"-"`
+	Text        string    `json:"text"`
+	Type        string    `json:"type,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	File        string    `json:"file,omitempty"`
+	Cooldown    Duration  `json:"cooldown,omitempty"`
+	Mode        string    `json:"mode,omitempty"`
+	Repeat      int       `json:"repeat,omitempty"`
+	Shuffle     bool      `json:"shuffle,omitempty"`
+	Playlist    string    `json:"playlist,omitempty"`
+	Glob        string    `json:"glob,omitempty"`
+	MaxDuration Duration  `json:"max_duration,omitempty"`
+	Priority    int       `json:"priority,omitempty"`
+	FadeIn      *Duration `json:"fade_in,omitempty"`
+	FadeOut     *Duration `json:"fade_out,omitempty"`
+	TrackFade   *Duration `json:"track_fade,omitempty"`
+	IsExtra     bool      `json:"-"`
+}
+
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(value * float64(time.Second)))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 func ParseDeviceConfig(data []byte) (DeviceConfig, error) {
@@ -102,8 +147,25 @@ func (c DeviceConfig) Validate() error {
 			if cmd.Text == "" {
 				return fmt.Errorf("device %s: text cannot be empty for command %s", deviceName, audioName)
 			}
+			if cmd.Cooldown < 0 {
+				return fmt.Errorf("device %s: cooldown cannot be negative for command %s", deviceName, audioName)
+			}
 
-			if cmd.Type == "folder" {
+			if cmd.Type == "template" {
+				if _, err := cmd.ParseTemplate(audioName); err != nil {
+					return fmt.Errorf("device %s: invalid template for command %s: %w", deviceName, audioName, err)
+				}
+			} else if cmd.Type == "folder" {
+				if err := cmd.validateFolderOptions(); err != nil {
+					return fmt.Errorf("device %s: command %s: %w", deviceName, audioName, err)
+				}
+				if cmd.Playlist != "" {
+					playlistPath := cmd.GetPlaylistPath(deviceName, audioName)
+					if _, err := os.Stat(playlistPath); err != nil {
+						return fmt.Errorf("device %s: playlist not found: %s", deviceName, playlistPath)
+					}
+					continue
+				}
 				dirPath := cmd.GetFolderPath(deviceName, audioName)
 				info, err := os.Stat(dirPath)
 				if err != nil {
@@ -130,7 +192,7 @@ func (c DeviceConfig) Validate() error {
 					return fmt.Errorf("device %s: folder directory is empty: %s", deviceName, dirPath)
 				}
 			} else {
-				audioPath := GetAudioFilePathForCommand(deviceName, audioName, cmd.IsExtra)
+				audioPath := cmd.GetAudioPath(deviceName, audioName)
 				if _, err := os.Stat(audioPath); err != nil {
 					if os.IsNotExist(err) {
 						return fmt.Errorf("device %s: audio file not found: %s", deviceName, audioPath)
@@ -158,6 +220,12 @@ func MergeConfigs(base, extra DeviceConfig) DeviceConfig {
 			if device.Volume != nil {
 				existing.Volume = device.Volume
 			}
+			if device.TTSEngine != "" {
+				existing.TTSEngine = device.TTSEngine
+			}
+			if device.Voice != "" {
+				existing.Voice = device.Voice
+			}
 			for audioName, cmd := range device.Commands {
 				existing.Commands[audioName] = cmd
 			}
@@ -170,15 +238,26 @@ func MergeConfigs(base, extra DeviceConfig) DeviceConfig {
 }
 
 func GetAudioFilePath(deviceName, audioName string) string {
-	return filepath.Join(GetEnv("AUDIO_BASE_PATH", "/audio"), deviceName, audioName+".wav")
+	return GetAudioFilePathForCommand(deviceName, audioName, false)
 }
 
-func GetAudioFilePathForCommand(deviceName, audioName string, isExtra bool) string {
+func GetAudioDir(deviceName string, isExtra bool) string {
 	base := GetEnv("AUDIO_BASE_PATH", "/audio")
 	if isExtra {
-		return filepath.Join(base, "extra", deviceName, audioName+".wav")
+		return filepath.Join(base, "extra", deviceName)
 	}
-	return filepath.Join(base, deviceName, audioName+".wav")
+	return filepath.Join(base, deviceName)
+}
+
+func GetAudioFilePathForCommand(deviceName, audioName string, isExtra bool) string {
+	dir := GetAudioDir(deviceName, isExtra)
+	for _, ext := range AudioExtensions {
+		path := filepath.Join(dir, audioName+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, audioName+AudioExtensions[0])
 }
 
 func GetFolderDirPath(deviceName, audioName string, isExtra bool) string {
@@ -196,6 +275,119 @@ func (c Command) GetFolderPath(deviceName, audioName string) string {
 	return GetFolderDirPath(deviceName, audioName, c.IsExtra)
 }
 
+func (c Command) validateFolderOptions() error {
+	switch c.Mode {
+	case "", "loop", "once":
+	case "repeat":
+		if c.Repeat < 1 {
+			return fmt.Errorf("repeat mode needs repeat >= 1")
+		}
+	default:
+		return fmt.Errorf("unknown folder mode %q", c.Mode)
+	}
+	if c.Glob != "" {
+		if _, err := filepath.Match(c.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", c.Glob, err)
+		}
+	}
+	if c.MaxDuration < 0 {
+		return fmt.Errorf("max_duration cannot be negative")
+	}
+	for name, d := range map[string]*Duration{"fade_in": c.FadeIn, "fade_out": c.FadeOut, "track_fade": c.TrackFade} {
+		if d != nil && *d < 0 {
+			return fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+	return nil
+}
+
+func (c Command) FolderFades() (fadeIn, fadeOut, trackFade time.Duration) {
+	pick := func(override *Duration, fallback time.Duration) time.Duration {
+		if override != nil {
+			return time.Duration(*override)
+		}
+		return fallback
+	}
+	return pick(c.FadeIn, GetFolderFadeIn()), pick(c.FadeOut, GetFolderFadeOut()), pick(c.TrackFade, GetFolderTrackFade())
+}
+
+func (c Command) GetPlaylistPath(deviceName, audioName string) string {
+	if c.Playlist == "" || filepath.IsAbs(c.Playlist) {
+		return c.Playlist
+	}
+	return filepath.Join(c.GetFolderPath(deviceName, audioName), c.Playlist)
+}
+
+func (c Command) ParseTemplate(name string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(c.Text)
+}
+
+func (c Command) TemplateParams(name string) []string {
+	tmpl, err := c.ParseTemplate(name)
+	if err != nil || tmpl.Tree == nil {
+		return nil
+	}
+
+	var params []string
+	seen := make(map[string]bool)
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if !seen[n.Ident[0]] {
+				seen[n.Ident[0]] = true
+				params = append(params, n.Ident[0])
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+		case *parse.WithNode:
+			walk(n.Pipe)
+		}
+	}
+	walk(tmpl.Tree.Root)
+	return params
+}
+
+func (c Command) GetAudioPath(deviceName, audioName string) string {
+	if c.Type == "template" {
+		return ""
+	}
+	if c.Type == "folder" {
+		return c.GetFolderPath(deviceName, audioName)
+	}
+	if c.File != "" {
+		if filepath.IsAbs(c.File) {
+			return c.File
+		}
+		return filepath.Join(GetAudioDir(deviceName, c.IsExtra), c.File)
+	}
+	return GetAudioFilePathForCommand(deviceName, audioName, c.IsExtra)
+}
+
 func ApplyVolumeOverrides(cfg DeviceConfig, logger *slog.Logger) {
 	for deviceName, device := range cfg {
 		envKey := strings.ToUpper(deviceName) + "_VOLUME_OVERRIDE"
@@ -232,6 +424,15 @@ func GetEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
 func GetEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -245,10 +446,152 @@ func IsPiperEmbedded() bool {
 	return GetEnvBool("PIPER_EMBEDDED", false)
 }
 
-func GetPiperSampleRate() int {
-	return GetEnvInt("PIPER_SAMPLE_RATE", 16000)
+func GetPiperMaxWorkers() int {
+	return GetEnvInt("PIPER_MAX_WORKERS", 2)
+}
+
+func GetPiperWorkerIdleTimeout() time.Duration {
+	return GetEnvDuration("PIPER_WORKER_IDLE_TIMEOUT", 10*time.Minute)
+}
+
+func GetPiperSynthesisTimeout() time.Duration {
+	return GetEnvDuration("PIPER_SYNTHESIS_TIMEOUT", time.Minute)
 }
 
 func GetDefaultVoice() string {
 	return GetEnv("VOICE", "en_US-amy-low")
 }
+
+func GetTTSEngine() string {
+	return GetEnv("TTS_ENGINE", "piper")
+}
+
+func GetEspeakVoice() string {
+	return GetEnv("ESPEAK_VOICE", "en")
+}
+
+func GetTTSCommand() string {
+	return GetEnv("TTS_COMMAND", "")
+}
+
+func GetTTSCommandVoice() string {
+	return GetEnv("TTS_COMMAND_VOICE", "")
+}
+
+func GetTTSHTTPURL() string {
+	return GetEnv("TTS_HTTP_URL", "")
+}
+
+func GetTTSHTTPModel() string {
+	return GetEnv("TTS_HTTP_MODEL", "tts-1")
+}
+
+func GetTTSHTTPVoice() string {
+	return GetEnv("TTS_HTTP_VOICE", "alloy")
+}
+
+func GetTTSHTTPAPIKey() string {
+	return GetEnv("TTS_HTTP_API_KEY", "")
+}
+
+func GetTTSHTTPTimeout() time.Duration {
+	return GetEnvDuration("TTS_HTTP_TIMEOUT", 60*time.Second)
+}
+
+func GetTTSCacheDir() string {
+	return GetEnv("TTS_CACHE_DIR", "/tmp/jacadi/tts-cache")
+}
+
+func GetTTSCacheSize() int {
+	return GetEnvInt("TTS_CACHE_SIZE", 256)
+}
+
+func GetComposeGap() time.Duration {
+	return GetEnvDuration("COMPOSE_GAP", 150*time.Millisecond)
+}
+
+func GetPlayURLAllowedHosts() string {
+	return GetEnv("PLAY_URL_ALLOWED_HOSTS", "")
+}
+
+func GetPlayURLTimeout() time.Duration {
+	return GetEnvDuration("PLAY_URL_TIMEOUT", 30*time.Second)
+}
+
+func GetPlayMaxSize() int {
+	return GetEnvInt("PLAY_MAX_SIZE_MB", 32)
+}
+
+func GetFolderFadeIn() time.Duration {
+	return GetEnvDuration("FOLDER_FADE_IN", 0)
+}
+
+func GetFolderFadeOut() time.Duration {
+	return GetEnvDuration("FOLDER_FADE_OUT", 0)
+}
+
+func GetFolderTrackFade() time.Duration {
+	return GetEnvDuration("FOLDER_TRACK_FADE", 0)
+}
+
+func GetVolumeStep() int {
+	return GetEnvInt("VOLUME_STEP", 5)
+}
+
+func GetVolumeUnmuteOnPlay() bool {
+	return GetEnvBool("VOLUME_UNMUTE_ON_PLAY", false)
+}
+
+func GetVolumeCalibration() string {
+	return GetEnv("VOLUME_CALIBRATION", "")
+}
+
+func GetMPVSocket() string {
+	return GetEnv("MPV_SOCKET", "/tmp/jacadi-mpv.sock")
+}
+
+func GetOTLPTracesURL() string {
+	if endpoint := GetEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", ""); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := GetEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+func GetOTelServiceName() string {
+	return GetEnv("OTEL_SERVICE_NAME", "jacadi")
+}
+
+func GetVoicesDir() string {
+	return GetEnv("VOICES_DIR", ".")
+}
+
+func GetRateLimitClient() int {
+	return GetEnvInt("RATE_LIMIT_CLIENT", 0)
+}
+
+func GetRateLimitToken() int {
+	return GetEnvInt("RATE_LIMIT_TOKEN", 0)
+}
+
+func GetRateLimitCommand() int {
+	return GetEnvInt("RATE_LIMIT_COMMAND", 0)
+}
+
+func GetRateLimitBurst() int {
+	return GetEnvInt("RATE_LIMIT_BURST", 5)
+}
+
+func GetDataDir() string {
+	return GetEnv("DATA_DIR", "/data")
+}
+
+func GetHistoryPath() string {
+	return GetEnv("HISTORY_PATH", filepath.Join(GetDataDir(), "history.jsonl"))
+}
+
+func GetHistoryMaxSize() int64 {
+	return int64(GetEnvInt("HISTORY_MAX_SIZE_MB", 10)) << 20
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/logging"
+)
+
+const (
+	calibrationSampleRate = 48000
+	calibrationGap        = 400 * time.Millisecond
+	maxCalibrationSteps   = 20
+)
+
+type CalibrationHandler struct {
+	coordinator *audio.Coordinator
+	logger      *slog.Logger
+}
+
+type CalibrationRequest struct {
+	Steps     int     `json:"steps,omitempty"`
+	Frequency float64 `json:"frequency,omitempty"`
+	Duration  float64 `json:"duration,omitempty"`
+	Min       *int    `json:"min,omitempty"`
+	Max       *int    `json:"max,omitempty"`
+	Curve     string  `json:"curve,omitempty"`
+}
+
+type CalibrationStep struct {
+	Volume  int `json:"volume"`
+	Percent int `json:"percent"`
+}
+
+type CalibrationResponse struct {
+	Status      string            `json:"status"`
+	Calibration audio.Calibration `json:"calibration"`
+	Steps       []CalibrationStep `json:"steps"`
+	Timestamp   string            `json:"timestamp"`
+}
+
+func NewCalibrationHandler(coordinator *audio.Coordinator, logger *slog.Logger) *CalibrationHandler {
+	return &CalibrationHandler{
+		coordinator: coordinator,
+		logger:      logger,
+	}
+}
+
+func (req CalibrationRequest) calibration() (audio.Calibration, error) {
+	calibration := audio.SinkCalibration()
+	if req.Min != nil {
+		calibration.Min = *req.Min
+	}
+	if req.Max != nil {
+		calibration.Max = *req.Max
+	}
+	if req.Curve != "" {
+		calibration.Curve = strings.ToLower(req.Curve)
+	}
+	return calibration, calibration.Validate()
+}
+
+func (req CalibrationRequest) validate() error {
+	switch {
+	case req.Steps < 0 || req.Steps > maxCalibrationSteps:
+		return fmt.Errorf("steps must be between 1 and %d", maxCalibrationSteps)
+	case req.Frequency != 0 && (req.Frequency < 20 || req.Frequency > 20000):
+		return fmt.Errorf("frequency must be between 20 and 20000 Hz")
+	case req.Duration != 0 && (req.Duration < 0.1 || req.Duration > 5):
+		return fmt.Errorf("duration must be between 0.1 and 5 seconds")
+	}
+	return nil
+}
+
+func (h *CalibrationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	req := CalibrationRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Error("invalid request body", "error", err, "remote_addr", r.RemoteAddr)
+			writeError(w, r, CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+	calibration, err := req.calibration()
+	if err != nil {
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	steps := req.Steps
+	if steps == 0 {
+		steps = 5
+	}
+	frequency := req.Frequency
+	if frequency == 0 {
+		frequency = 440
+	}
+	duration := time.Second
+	if req.Duration != 0 {
+		duration = time.Duration(req.Duration * float64(time.Second))
+	}
+
+	tone := audio.Tone(frequency, duration, calibrationSampleRate)
+	tone.Data = append(tone.Data, tone.Silence(calibrationGap)...)
+
+	volumes := make([]int, steps)
+	response := CalibrationResponse{
+		Status:      "playing",
+		Calibration: calibration,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	for i := range volumes {
+		volumes[i] = 100 * (i + 1) / steps
+		response.Steps = append(response.Steps, CalibrationStep{Volume: volumes[i], Percent: calibration.Apply(volumes[i])})
+	}
+
+	if err := h.coordinator.PlayCalibration(r.Context(), tone, calibration, volumes, nil); err != nil {
+		logger.Error("calibration failed", "error", err, "remote_addr", r.RemoteAddr)
+		writeError(w, r, playbackErrorCode(err), err.Error())
+		return
+	}
+
+	logger.Info("calibration started",
+		"steps", steps,
+		"min", calibration.Min,
+		"max", calibration.Max,
+		"curve", calibration.Curve,
+		"remote_addr", r.RemoteAddr,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/config"
+	"jacadi/history"
+	"jacadi/logging"
+	"jacadi/tracing"
+)
+
+type clipPlayer struct {
+	coordinator  *audio.Coordinator
+	deviceConfig config.DeviceConfig
+	maxSize      int64
+	history      *history.Store
+	logger       *slog.Logger
+}
+
+type PlayURLHandler struct {
+	clipPlayer
+	allowed *HostAllowList
+	client  *http.Client
+}
+
+type PlayRawHandler struct {
+	clipPlayer
+}
+
+type PlayURLRequest struct {
+	URL    string `json:"url"`
+	Device string `json:"device,omitempty"`
+}
+
+type ClipResponse struct {
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Timestamp       string  `json:"timestamp"`
+}
+
+func NewPlayURLHandler(coordinator *audio.Coordinator, deviceConfig config.DeviceConfig, allowed *HostAllowList, timeout time.Duration, maxSize int64, store *history.Store, logger *slog.Logger) *PlayURLHandler {
+	h := &PlayURLHandler{
+		clipPlayer: clipPlayer{
+			coordinator:  coordinator,
+			deviceConfig: deviceConfig,
+			maxSize:      maxSize,
+			history:      store,
+			logger:       logger,
+		},
+		allowed: allowed,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = allowed.DialContext
+	h.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			return allowed.Check(req.Context(), req.URL.Hostname())
+		},
+	}
+	return h
+}
+
+func NewPlayRawHandler(coordinator *audio.Coordinator, deviceConfig config.DeviceConfig, maxSize int64, store *history.Store, logger *slog.Logger) *PlayRawHandler {
+	return &PlayRawHandler{
+		clipPlayer: clipPlayer{
+			coordinator:  coordinator,
+			deviceConfig: deviceConfig,
+			maxSize:      maxSize,
+			history:      store,
+			logger:       logger,
+		},
+	}
+}
+
+func (h *PlayURLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	var req PlayURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("invalid request body",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	target, err := url.Parse(req.URL)
+	if err == nil && target.Scheme != "http" && target.Scheme != "https" {
+		err = fmt.Errorf("url must be http or https")
+	}
+	if err == nil {
+		err = h.allowed.Check(r.Context(), target.Hostname())
+	}
+	if err != nil {
+		logger.Error("url rejected",
+			"error", err,
+			"url", req.URL,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeURLNotAllowed, err.Error())
+		return
+	}
+
+	data, err := h.fetch(r, target.String())
+	if err != nil {
+		logger.Error("failed to fetch audio",
+			"error", err,
+			"url", req.URL,
+			"remote_addr", r.RemoteAddr,
+		)
+		code := CodeFetchFailed
+		if errors.Is(err, ErrHostNotAllowed) {
+			code = CodeURLNotAllowed
+		}
+		writeError(w, r, code, err.Error())
+		return
+	}
+
+	h.play(w, r, data, "url", req.Device)
+}
+
+func (h *PlayURLHandler) fetch(r *http.Request, target string) (data []byte, err error) {
+	ctx, span := tracing.StartClient(r.Context(), "GET", "url.full", target)
+	defer func() {
+		span.SetAttributes("bytes", len(data))
+		span.SetError(err)
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(TraceparentHeader, span.Context().Traceparent())
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return readLimited(resp.Body, h.maxSize)
+}
+
+func (h *PlayRawHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	data, err := readLimited(r.Body, h.maxSize)
+	if err != nil {
+		logger.Error("invalid request body",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	h.play(w, r, data, "raw", r.URL.Query().Get("device"))
+}
+
+func readLimited(r io.Reader, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("audio larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+func (h *clipPlayer) play(w http.ResponseWriter, r *http.Request, data []byte, command, deviceName string) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	var volume *int
+	if deviceName != "" {
+		device, ok := lookupDevice(w, r, h.deviceConfig, deviceName)
+		if !ok {
+			return
+		}
+		volume = device.Volume
+	}
+
+	entry := history.Entry{
+		Device:     deviceName,
+		Command:    command,
+		Volume:     volume,
+		RemoteAddr: r.RemoteAddr,
+	}
+	record := func(outcome string, err error, duration time.Duration) {
+		entry.Timestamp = time.Now().Add(-duration)
+		entry.Outcome = outcome
+		entry.DurationMs = duration.Milliseconds()
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		h.history.Record(entry)
+	}
+
+	pcm, err := audio.Decode(data)
+	if err != nil {
+		logger.Error("failed to decode audio",
+			"error", err,
+			"bytes", len(data),
+			"remote_addr", r.RemoteAddr,
+		)
+		record(history.OutcomeRejected, err, 0)
+		code := CodeAudioInvalid
+		switch {
+		case errors.Is(err, audio.ErrUnrecognizedAudio):
+			code = CodeFormatUnsupported
+		case errors.Is(err, audio.ErrUnsupportedFormat):
+			code = CodeAudioUnsupported
+		}
+		writeError(w, r, code, err.Error())
+		return
+	}
+
+	start := time.Now()
+	done := func(err error) {
+		if err != nil {
+			record(history.OutcomeFailed, err, time.Since(start))
+			return
+		}
+		record(history.OutcomeCompleted, nil, time.Since(start))
+	}
+
+	if err := h.coordinator.PlayPCMAsync(r.Context(), pcm, volume, done); err != nil {
+		logger.Error("playback failed",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, playbackErrorCode(err), err.Error())
+		return
+	}
+
+	logger.Info("clip playback started",
+		"source", command,
+		"duration", pcm.Duration(),
+		"remote_addr", r.RemoteAddr,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ClipResponse{
+		Status:          "playing",
+		DurationSeconds: pcm.Duration().Seconds(),
+		Timestamp:       time.Now().Format(time.RFC3339),
+	})
+}
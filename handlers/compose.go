@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/config"
+	"jacadi/history"
+	"jacadi/logging"
+	"jacadi/tts"
+)
+
+const (
+	maxComposeParts = 32
+	maxComposeGap   = 10 * time.Second
+)
+
+type ComposeHandler struct {
+	coordinator  *audio.Coordinator
+	deviceConfig config.DeviceConfig
+	engines      *tts.Registry
+	cache        *tts.Cache
+	defaultGap   time.Duration
+	history      *history.Store
+	logger       *slog.Logger
+}
+
+type ComposePart struct {
+	Command string   `json:"command,omitempty"`
+	Text    string   `json:"text,omitempty"`
+	Voice   string   `json:"voice,omitempty"`
+	Engine  string   `json:"engine,omitempty"`
+	Gap     *float64 `json:"gap,omitempty"`
+}
+
+type ComposeRequest struct {
+	Parts []ComposePart `json:"parts"`
+	Gap   *float64      `json:"gap,omitempty"`
+}
+
+type ComposeResponse struct {
+	Status          string  `json:"status"`
+	Parts           int     `json:"parts"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Timestamp       string  `json:"timestamp"`
+}
+
+type composeError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *composeError) Error() string {
+	return e.err.Error()
+}
+
+func NewComposeHandler(coordinator *audio.Coordinator, deviceConfig config.DeviceConfig, engines *tts.Registry, cache *tts.Cache, defaultGap time.Duration, store *history.Store, logger *slog.Logger) *ComposeHandler {
+	return &ComposeHandler{
+		coordinator:  coordinator,
+		deviceConfig: deviceConfig,
+		engines:      engines,
+		cache:        cache,
+		defaultGap:   defaultGap,
+		history:      store,
+		logger:       logger,
+	}
+}
+
+func parseGap(seconds *float64, fallback time.Duration) (time.Duration, error) {
+	if seconds == nil {
+		return fallback, nil
+	}
+	gap := time.Duration(*seconds * float64(time.Second))
+	if gap < 0 || gap > maxComposeGap {
+		return 0, fmt.Errorf("gap must be between 0 and %s", maxComposeGap)
+	}
+	return gap, nil
+}
+
+func (h *ComposeHandler) resolve(ctx context.Context, deviceName string, device config.Device, part ComposePart) (audio.PCM, error) {
+	invalid := func(format string, args ...any) error {
+		return &composeError{CodeInvalidRequest, fmt.Errorf(format, args...)}
+	}
+
+	switch {
+	case part.Command != "" && part.Text != "":
+		return audio.PCM{}, invalid("a part has either a command or a text, not both")
+	case part.Command != "":
+		cmd, ok := device.Commands[part.Command]
+		if !ok {
+			return audio.PCM{}, &composeError{CodeCommandNotFound, fmt.Errorf("no command named %q on %s", part.Command, deviceName)}
+		}
+		if cmd.Type != "" {
+			return audio.PCM{}, invalid("command %s is a %s command, only single file commands can be composed", part.Command, cmd.Type)
+		}
+		pcm, err := audio.DecodeFile(cmd.GetAudioPath(deviceName, part.Command))
+		if os.IsNotExist(err) {
+			return audio.PCM{}, &composeError{CodeAudioNotFound, err}
+		}
+		if err != nil {
+			return audio.PCM{}, &composeError{CodeInternal, err}
+		}
+		return pcm, nil
+	case part.Text != "":
+		if h.cache == nil {
+			return audio.PCM{}, &composeError{CodeTTSUnavailable, fmt.Errorf("TTS is not available")}
+		}
+		engineName, voice := part.Engine, part.Voice
+		if engineName == "" {
+			engineName = device.TTSEngine
+		}
+		if voice == "" {
+			voice = device.Voice
+		}
+		engine, err := h.engines.Get(engineName)
+		if err != nil {
+			return audio.PCM{}, &composeError{CodeTTSEngineUnknown, err}
+		}
+		if voice == "" {
+			voice = engine.DefaultVoice()
+		}
+		if err := engine.ValidateVoice(voice); err != nil {
+			return audio.PCM{}, &composeError{ttsErrorCode(err), err}
+		}
+		wav, _, err := h.cache.Render(ctx, engine, tts.Request{Text: part.Text, Voice: voice})
+		if err != nil {
+			return audio.PCM{}, &composeError{ttsErrorCode(err), err}
+		}
+		pcm, err := audio.Decode(wav)
+		if err != nil {
+			return audio.PCM{}, &composeError{CodeInternal, err}
+		}
+		return pcm, nil
+	default:
+		return audio.PCM{}, invalid("a part needs a command or a text")
+	}
+}
+
+func (h *ComposeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	deviceName := r.PathValue("device")
+	device, ok := lookupDevice(w, r, h.deviceConfig, deviceName)
+	if !ok {
+		return
+	}
+
+	entry := history.Entry{
+		Device:     deviceName,
+		Command:    "compose",
+		Volume:     device.Volume,
+		RemoteAddr: r.RemoteAddr,
+	}
+	record := func(outcome string, err error, duration time.Duration) {
+		entry.Timestamp = time.Now().Add(-duration)
+		entry.Outcome = outcome
+		entry.DurationMs = duration.Milliseconds()
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		h.history.Record(entry)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSpeechBodySize)
+	var req ComposeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err == nil && len(req.Parts) == 0 {
+		err = fmt.Errorf("parts cannot be empty")
+	}
+	if err == nil && len(req.Parts) > maxComposeParts {
+		err = fmt.Errorf("at most %d parts can be composed", maxComposeParts)
+	}
+	if err != nil {
+		logger.Error("invalid request body",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	start := time.Now()
+	var texts []string
+	clips := make([]audio.PCM, 0, len(req.Parts))
+	gaps := make([]time.Duration, 0, len(req.Parts))
+	defaultGap, err := parseGap(req.Gap, h.defaultGap)
+	for i := 0; err == nil && i < len(req.Parts); i++ {
+		part := req.Parts[i]
+		var gap time.Duration
+		if gap, err = parseGap(part.Gap, defaultGap); err != nil {
+			break
+		}
+		var clip audio.PCM
+		if clip, err = h.resolve(r.Context(), deviceName, device, part); err != nil {
+			err = fmt.Errorf("part %d: %w", i, err)
+			break
+		}
+		clips = append(clips, clip)
+		gaps = append(gaps, gap)
+		texts = append(texts, part.Command+part.Text)
+	}
+
+	var stream audio.PCM
+	if err == nil {
+		stream, err = audio.Concat(clips, gaps)
+	}
+	if err != nil {
+		code := CodeInvalidRequest
+		var cerr *composeError
+		if errors.As(err, &cerr) {
+			code = cerr.code
+		}
+		logger.Error("compose failed",
+			"error", err,
+			"device", deviceName,
+			"remote_addr", r.RemoteAddr,
+		)
+		record(history.OutcomeRejected, err, 0)
+		writeError(w, r, code, err.Error())
+		return
+	}
+	entry.TextHash = history.HashText(strings.Join(texts, "\n"))
+
+	done := func(err error) {
+		if err != nil {
+			record(history.OutcomeFailed, err, time.Since(start))
+			return
+		}
+		record(history.OutcomeCompleted, nil, time.Since(start))
+	}
+
+	if err := h.coordinator.PlayPCMAsync(r.Context(), stream, device.Volume, done); err != nil {
+		logger.Error("playback failed",
+			"error", err,
+			"device", deviceName,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, playbackErrorCode(err), err.Error())
+		return
+	}
+
+	logger.Info("composed playback started",
+		"device", deviceName,
+		"parts", len(clips),
+		"duration", stream.Duration(),
+		"remote_addr", r.RemoteAddr,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ComposeResponse{
+		Status:          "playing",
+		Parts:           len(clips),
+		DurationSeconds: stream.Duration().Seconds(),
+		Timestamp:       time.Now().Format(time.RFC3339),
+	})
+}
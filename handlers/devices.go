@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/config"
+)
+
+type DevicesHandler struct {
+	deviceConfig config.DeviceConfig
+	logger       *slog.Logger
+}
+
+type DeviceInfo struct {
+	Name         string        `json:"name"`
+	Volume       *int          `json:"volume,omitempty"`
+	CommandCount int           `json:"command_count"`
+	Commands     []CommandInfo `json:"commands,omitempty"`
+}
+
+type CommandInfo struct {
+	Name            string   `json:"name"`
+	Text            string   `json:"text"`
+	Type            string   `json:"type"`
+	Path            string   `json:"path,omitempty"`
+	Route           string   `json:"route"`
+	Volume          *int     `json:"volume,omitempty"`
+	Cooldown        string   `json:"cooldown,omitempty"`
+	Params          []string `json:"params,omitempty"`
+	AudioExists     bool     `json:"audio_exists"`
+	DurationSeconds float64  `json:"duration_seconds,omitempty"`
+	Extra           bool     `json:"extra"`
+}
+
+type DevicesResponse struct {
+	Devices []DeviceInfo `json:"devices"`
+}
+
+type CommandsResponse struct {
+	Device   string        `json:"device"`
+	Commands []CommandInfo `json:"commands"`
+}
+
+func NewDevicesHandler(deviceConfig config.DeviceConfig, logger *slog.Logger) *DevicesHandler {
+	return &DevicesHandler{
+		deviceConfig: deviceConfig,
+		logger:       logger,
+	}
+}
+
+func (h *DevicesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(h.deviceConfig))
+	for name := range h.deviceConfig {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	devices := make([]DeviceInfo, 0, len(names))
+	for _, name := range names {
+		device := h.deviceConfig[name]
+		devices = append(devices, DeviceInfo{
+			Name:         name,
+			Volume:       device.Volume,
+			CommandCount: len(device.Commands),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DevicesResponse{Devices: devices})
+}
+
+type DeviceHandler struct {
+	deviceConfig config.DeviceConfig
+	templates    bool
+	logger       *slog.Logger
+}
+
+func NewDeviceHandler(deviceConfig config.DeviceConfig, templates bool, logger *slog.Logger) *DeviceHandler {
+	return &DeviceHandler{
+		deviceConfig: deviceConfig,
+		templates:    templates,
+		logger:       logger,
+	}
+}
+
+func (h *DeviceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("device")
+	device, ok := lookupDevice(w, r, h.deviceConfig, name)
+	if !ok {
+		return
+	}
+
+	commands := commandInfos(name, device, h.templates)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DeviceInfo{
+		Name:         name,
+		Volume:       device.Volume,
+		CommandCount: len(commands),
+		Commands:     commands,
+	})
+}
+
+type DeviceCommandsHandler struct {
+	deviceConfig config.DeviceConfig
+	templates    bool
+	logger       *slog.Logger
+}
+
+func NewDeviceCommandsHandler(deviceConfig config.DeviceConfig, templates bool, logger *slog.Logger) *DeviceCommandsHandler {
+	return &DeviceCommandsHandler{
+		deviceConfig: deviceConfig,
+		templates:    templates,
+		logger:       logger,
+	}
+}
+
+func (h *DeviceCommandsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("device")
+	device, ok := lookupDevice(w, r, h.deviceConfig, name)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(CommandsResponse{
+		Device:   name,
+		Commands: commandInfos(name, device, h.templates),
+	})
+}
+
+func lookupDevice(w http.ResponseWriter, r *http.Request, deviceConfig config.DeviceConfig, name string) (config.Device, bool) {
+	device, ok := deviceConfig[name]
+	if !ok {
+		writeError(w, r, CodeDeviceNotFound, fmt.Sprintf("no device named %q", name))
+	}
+	return device, ok
+}
+
+func commandInfos(deviceName string, device config.Device, templates bool) []CommandInfo {
+	names := make([]string, 0, len(device.Commands))
+	for name := range device.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]CommandInfo, 0, len(names))
+	for _, name := range names {
+		cmd := device.Commands[name]
+		info := CommandInfo{
+			Name:   name,
+			Text:   cmd.Text,
+			Type:   cmd.Type,
+			Path:   cmd.GetAudioPath(deviceName, name),
+			Route:  fmt.Sprintf("POST /play/%s/%s", deviceName, name),
+			Volume: device.Volume,
+			Extra:  cmd.IsExtra,
+		}
+		if info.Type == "" {
+			info.Type = "file"
+		}
+		if cmd.Cooldown > 0 {
+			info.Cooldown = time.Duration(cmd.Cooldown).String()
+		}
+
+		if cmd.Type == "template" {
+			info.AudioExists = templates
+			info.Params = cmd.TemplateParams(name)
+		} else if stat, err := os.Stat(info.Path); err == nil {
+			if cmd.Type == "folder" {
+				info.AudioExists = stat.IsDir()
+			} else {
+				info.AudioExists = !stat.IsDir()
+				if duration, err := audio.FileDuration(info.Path); err == nil {
+					info.DurationSeconds = duration.Seconds()
+				}
+			}
+		}
+
+		infos = append(infos, info)
+	}
+	return infos
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"jacadi/audio"
+	"jacadi/tts"
+)
+
+type ErrorCode string
+
+const (
+	CodeInvalidRequest        ErrorCode = "INVALID_REQUEST"
+	CodeDeviceNotFound        ErrorCode = "DEVICE_NOT_FOUND"
+	CodeCommandNotFound       ErrorCode = "COMMAND_NOT_FOUND"
+	CodeAudioNotFound         ErrorCode = "AUDIO_NOT_FOUND"
+	CodeAudioInvalid          ErrorCode = "AUDIO_INVALID"
+	CodeAudioUnsupported      ErrorCode = "AUDIO_UNSUPPORTED"
+	CodeFormatUnsupported     ErrorCode = "FORMAT_UNSUPPORTED"
+	CodeEncoderUnavailable    ErrorCode = "ENCODER_UNAVAILABLE"
+	CodeURLNotAllowed         ErrorCode = "URL_NOT_ALLOWED"
+	CodeFetchFailed           ErrorCode = "FETCH_FAILED"
+	CodePlaybackFailed        ErrorCode = "PLAYBACK_FAILED"
+	CodeDeviceBusy            ErrorCode = "DEVICE_BUSY"
+	CodeFolderNotPlaying      ErrorCode = "FOLDER_NOT_PLAYING"
+	CodeFolderSessionNotFound ErrorCode = "FOLDER_SESSION_NOT_FOUND"
+	CodeMixerUnavailable      ErrorCode = "MIXER_UNAVAILABLE"
+	CodeMixerNotFound         ErrorCode = "MIXER_NOT_FOUND"
+	CodeMixerUnsupported      ErrorCode = "MIXER_UNSUPPORTED"
+	CodeTTSUnavailable        ErrorCode = "TTS_UNAVAILABLE"
+	CodeTTSEngineUnknown      ErrorCode = "TTS_ENGINE_UNKNOWN"
+	CodeTTSVoiceUnknown       ErrorCode = "TTS_VOICE_UNKNOWN"
+	CodeTTSInvalidSSML        ErrorCode = "TTS_INVALID_SSML"
+	CodeTTSFailed             ErrorCode = "TTS_FAILED"
+	CodeTemplateInvalid       ErrorCode = "TEMPLATE_INVALID"
+	CodeVoiceExists           ErrorCode = "VOICE_EXISTS"
+	CodeVoiceInstallFailed    ErrorCode = "VOICE_INSTALL_FAILED"
+	CodeRateLimited           ErrorCode = "RATE_LIMITED"
+	CodeInternal              ErrorCode = "INTERNAL_ERROR"
+)
+
+type errorKind struct {
+	status int
+	title  string
+}
+
+var errorKinds = map[ErrorCode]errorKind{
+	CodeInvalidRequest:        {http.StatusBadRequest, "invalid request"},
+	CodeDeviceNotFound:        {http.StatusNotFound, "device not found"},
+	CodeCommandNotFound:       {http.StatusNotFound, "command not found"},
+	CodeAudioNotFound:         {http.StatusNotFound, "audio file not found"},
+	CodeAudioInvalid:          {http.StatusBadRequest, "invalid audio"},
+	CodeAudioUnsupported:      {http.StatusUnsupportedMediaType, "unsupported audio"},
+	CodeFormatUnsupported:     {http.StatusBadRequest, "unsupported format"},
+	CodeEncoderUnavailable:    {http.StatusNotAcceptable, "encoder unavailable"},
+	CodeURLNotAllowed:         {http.StatusForbidden, "url not allowed"},
+	CodeFetchFailed:           {http.StatusBadGateway, "failed to fetch audio"},
+	CodePlaybackFailed:        {http.StatusInternalServerError, "playback failed"},
+	CodeDeviceBusy:            {http.StatusConflict, "audio device busy"},
+	CodeFolderNotPlaying:      {http.StatusConflict, "no folder playing"},
+	CodeFolderSessionNotFound: {http.StatusNotFound, "folder session not found"},
+	CodeMixerUnavailable:      {http.StatusServiceUnavailable, "mixer unavailable"},
+	CodeMixerNotFound:         {http.StatusNotFound, "mixer control not found"},
+	CodeMixerUnsupported:      {http.StatusBadRequest, "unsupported mixer operation"},
+	CodeTTSUnavailable:        {http.StatusServiceUnavailable, "TTS unavailable"},
+	CodeTTSEngineUnknown:      {http.StatusBadRequest, "unknown engine"},
+	CodeTTSVoiceUnknown:       {http.StatusBadRequest, "unknown voice"},
+	CodeTTSInvalidSSML:        {http.StatusBadRequest, "invalid SSML"},
+	CodeTTSFailed:             {http.StatusInternalServerError, "TTS failed"},
+	CodeTemplateInvalid:       {http.StatusBadRequest, "template rendering failed"},
+	CodeVoiceExists:           {http.StatusConflict, "voice already installed"},
+	CodeVoiceInstallFailed:    {http.StatusBadRequest, "voice install failed"},
+	CodeRateLimited:           {http.StatusTooManyRequests, "rate limited"},
+	CodeInternal:              {http.StatusInternalServerError, "internal server error"},
+}
+
+type ErrorResponse struct {
+	Error     string    `json:"error"`
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message,omitempty"`
+	File      string    `json:"file,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+type Problem struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    int       `json:"status"`
+	Detail    string    `json:"detail,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Code      ErrorCode `json:"code"`
+	File      string    `json:"file,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, code ErrorCode, message string) {
+	writeErrorResponse(w, r, ErrorResponse{Code: code, Message: message})
+}
+
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, resp ErrorResponse) {
+	kind, ok := errorKinds[resp.Code]
+	if !ok {
+		resp.Code, kind = CodeInternal, errorKinds[CodeInternal]
+	}
+	resp.Error = kind.title
+	resp.RequestID = RequestID(r)
+
+	requestLogger(r).Warn("request failed",
+		"code", resp.Code,
+		"status", kind.status,
+		"message", resp.Message,
+		"path", r.URL.Path,
+		"remote_addr", r.RemoteAddr,
+	)
+
+	if !wantsProblem(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(kind.status)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(kind.status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:      "urn:jacadi:error:" + strings.ToLower(strings.ReplaceAll(string(resp.Code), "_", "-")),
+		Title:     kind.title,
+		Status:    kind.status,
+		Detail:    resp.Message,
+		Instance:  r.URL.Path,
+		Code:      resp.Code,
+		File:      resp.File,
+		RequestID: resp.RequestID,
+	})
+}
+
+func wantsProblem(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/problem+json")
+}
+
+func ttsErrorCode(err error) ErrorCode {
+	switch {
+	case errors.Is(err, tts.ErrUnknownVoice):
+		return CodeTTSVoiceUnknown
+	case errors.Is(err, tts.ErrInvalidSSML):
+		return CodeTTSInvalidSSML
+	case errors.Is(err, tts.ErrTooMuchSilence):
+		return CodeInvalidRequest
+	default:
+		return CodeTTSFailed
+	}
+}
+
+func playbackErrorCode(err error) ErrorCode {
+	if errors.Is(err, audio.ErrDeviceBusy) {
+		return CodeDeviceBusy
+	}
+	return CodePlaybackFailed
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/logging"
+)
+
+const (
+	FolderActionNext   = "next"
+	FolderActionPrev   = "prev"
+	FolderActionPause  = "pause"
+	FolderActionResume = "resume"
+	FolderActionSeek   = "seek"
+)
+
+type FolderControlHandler struct {
+	coordinator *audio.Coordinator
+	action      string
+	logger      *slog.Logger
+}
+
+type FolderStateHandler struct {
+	coordinator *audio.Coordinator
+	logger      *slog.Logger
+}
+
+type FolderSessionsHandler struct {
+	coordinator *audio.Coordinator
+	logger      *slog.Logger
+}
+
+type FolderSessionHandler struct {
+	coordinator *audio.Coordinator
+	logger      *slog.Logger
+}
+
+type FolderSessionStopHandler struct {
+	coordinator *audio.Coordinator
+	logger      *slog.Logger
+}
+
+type FolderSessionsResponse struct {
+	Sessions  []audio.FolderSession `json:"sessions"`
+	Timestamp string                `json:"timestamp"`
+}
+
+type SeekRequest struct {
+	Position float64 `json:"position"`
+	Relative bool    `json:"relative,omitempty"`
+}
+
+type FolderResponse struct {
+	Status    string             `json:"status"`
+	Folder    *audio.FolderState `json:"folder,omitempty"`
+	Timestamp string             `json:"timestamp"`
+}
+
+func NewFolderControlHandler(coordinator *audio.Coordinator, action string, logger *slog.Logger) *FolderControlHandler {
+	return &FolderControlHandler{
+		coordinator: coordinator,
+		action:      action,
+		logger:      logger,
+	}
+}
+
+func NewFolderStateHandler(coordinator *audio.Coordinator, logger *slog.Logger) *FolderStateHandler {
+	return &FolderStateHandler{
+		coordinator: coordinator,
+		logger:      logger,
+	}
+}
+
+func NewFolderSessionsHandler(coordinator *audio.Coordinator, logger *slog.Logger) *FolderSessionsHandler {
+	return &FolderSessionsHandler{
+		coordinator: coordinator,
+		logger:      logger,
+	}
+}
+
+func NewFolderSessionHandler(coordinator *audio.Coordinator, logger *slog.Logger) *FolderSessionHandler {
+	return &FolderSessionHandler{
+		coordinator: coordinator,
+		logger:      logger,
+	}
+}
+
+func NewFolderSessionStopHandler(coordinator *audio.Coordinator, logger *slog.Logger) *FolderSessionStopHandler {
+	return &FolderSessionStopHandler{
+		coordinator: coordinator,
+		logger:      logger,
+	}
+}
+
+func FolderSessionID(device, command string) string {
+	return device + "-" + command
+}
+
+func (h *FolderControlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	var err error
+	status := "playing"
+	switch h.action {
+	case FolderActionNext:
+		err = h.coordinator.NextTrack()
+	case FolderActionPrev:
+		err = h.coordinator.PrevTrack()
+	case FolderActionPause:
+		err = h.coordinator.PauseFolder()
+		status = "paused"
+	case FolderActionResume:
+		err = h.coordinator.ResumeFolder()
+	case FolderActionSeek:
+		var req SeekRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Error("invalid request body",
+				"error", err,
+				"remote_addr", r.RemoteAddr,
+			)
+			writeError(w, r, CodeInvalidRequest, err.Error())
+			return
+		}
+		err = h.coordinator.SeekFolder(req.Position, req.Relative)
+	}
+	if err != nil {
+		writeFolderError(w, r, err, "folder "+h.action+" failed", logger)
+		return
+	}
+
+	logger.Info("folder control", "action", h.action, "remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FolderResponse{
+		Status:    status,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+func (h *FolderStateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	state, err := h.coordinator.FolderState()
+	if err != nil {
+		writeFolderError(w, r, err, "folder state failed", logger)
+		return
+	}
+
+	status := "playing"
+	switch {
+	case state.Index < 0:
+		status = "interrupted"
+	case state.Paused:
+		status = "paused"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FolderResponse{
+		Status:    status,
+		Folder:    &state,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+func (h *FolderSessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FolderSessionsResponse{
+		Sessions:  h.coordinator.Sessions(),
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+func (h *FolderSessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	session, err := h.coordinator.Session(r.PathValue("id"))
+	if err != nil {
+		writeFolderError(w, r, err, "folder session failed", logger)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(session)
+}
+
+func (h *FolderSessionStopHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	id := r.PathValue("id")
+	if err := h.coordinator.StopSession(id); err != nil {
+		writeFolderError(w, r, err, "folder session stop failed", logger)
+		return
+	}
+
+	logger.Info("folder session stopped", "session", id, "remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PlaybackResponse{
+		Status:    "stopped",
+		Session:   id,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+func writeFolderError(w http.ResponseWriter, r *http.Request, err error, label string, logger *slog.Logger) {
+	code := CodePlaybackFailed
+	switch {
+	case errors.Is(err, audio.ErrFolderNotPlaying):
+		code = CodeFolderNotPlaying
+	case errors.Is(err, audio.ErrSessionNotFound):
+		code = CodeFolderSessionNotFound
+	}
+	logger.Error(label,
+		"error", err,
+		"remote_addr", r.RemoteAddr,
+	)
+	writeError(w, r, code, err.Error())
+}
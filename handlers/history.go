@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"jacadi/history"
+	"jacadi/logging"
+)
+
+const defaultHistoryLimit = 100
+
+type HistoryHandler struct {
+	store  *history.Store
+	logger *slog.Logger
+}
+
+type HistoryResponse struct {
+	Entries []history.Entry `json:"entries"`
+	Count   int             `json:"count"`
+}
+
+func NewHistoryHandler(store *history.Store, logger *slog.Logger) *HistoryHandler {
+	return &HistoryHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (h *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	query := history.Query{
+		Device: r.URL.Query().Get("device"),
+		Limit:  defaultHistoryLimit,
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			writeError(w, r, CodeInvalidRequest, "since must be an RFC3339 timestamp or a duration (e.g. 1h)")
+			return
+		}
+		query.Since = t
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			writeError(w, r, CodeInvalidRequest, "limit must be a positive integer")
+			return
+		}
+		query.Limit = n
+	}
+
+	entries, err := h.store.Query(query)
+	if err != nil {
+		logger.Error("history query failed",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInternal, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(HistoryResponse{
+		Entries: entries,
+		Count:   len(entries),
+	})
+}
+
+func parseSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-d), nil
+}
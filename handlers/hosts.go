@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrHostNotAllowed = errors.New("host is not allowed")
+
+var lanNetworks = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+type HostAllowList struct {
+	names []string
+	nets  []*net.IPNet
+}
+
+func ParseHostAllowList(spec string) (*HostAllowList, error) {
+	list := &HostAllowList{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "lan":
+			for _, cidr := range lanNetworks {
+				_, network, _ := net.ParseCIDR(cidr)
+				list.nets = append(list.nets, network)
+			}
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+			}
+			list.nets = append(list.nets, network)
+		default:
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				list.nets = append(list.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			list.names = append(list.names, entry)
+		}
+	}
+	return list, nil
+}
+
+func (l *HostAllowList) Empty() bool {
+	return len(l.names) == 0 && len(l.nets) == 0
+}
+
+func (l *HostAllowList) allowsName(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, name := range l.names {
+		if host == name {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(name, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *HostAllowList) Check(ctx context.Context, host string) error {
+	if l.Empty() {
+		return fmt.Errorf("%w: PLAY_URL_ALLOWED_HOSTS is not configured", ErrHostNotAllowed)
+	}
+	if l.allowsName(host) {
+		return nil
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		ips = ips[:0]
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	for _, ip := range ips {
+		if !l.containsIP(ip) {
+			return fmt.Errorf("%w: %s (%s)", ErrHostNotAllowed, host, ip)
+		}
+	}
+	return nil
+}
+
+func (l *HostAllowList) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !l.allowsName(host) {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			ipHost, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(ipHost); ip == nil || !l.containsIP(ip) {
+				return fmt.Errorf("%w: %s (%s)", ErrHostNotAllowed, host, ipHost)
+			}
+			return nil
+		}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+func (l *HostAllowList) containsIP(ip net.IP) bool {
+	for _, network := range l.nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/logging"
+)
+
+type MixerHandler struct {
+	logger *slog.Logger
+}
+
+type MixerControlHandler struct {
+	logger *slog.Logger
+}
+
+type MixerSetHandler struct {
+	logger *slog.Logger
+}
+
+type MixerMuteHandler struct {
+	muted  bool
+	logger *slog.Logger
+}
+
+type MixerResponse struct {
+	Cards          []audio.MixerCard `json:"cards"`
+	DefaultCard    string            `json:"default_card"`
+	DefaultControl string            `json:"default_control"`
+	Timestamp      string            `json:"timestamp"`
+}
+
+type MixerSetRequest struct {
+	Percent *int     `json:"percent,omitempty"`
+	DB      *float64 `json:"db,omitempty"`
+	Balance *float64 `json:"balance,omitempty"`
+}
+
+type MixerControlResponse struct {
+	Status    string             `json:"status"`
+	Card      string             `json:"card"`
+	Control   audio.MixerControl `json:"control"`
+	Timestamp string             `json:"timestamp"`
+}
+
+func NewMixerHandler(logger *slog.Logger) *MixerHandler {
+	return &MixerHandler{
+		logger: logger,
+	}
+}
+
+func NewMixerControlHandler(logger *slog.Logger) *MixerControlHandler {
+	return &MixerControlHandler{
+		logger: logger,
+	}
+}
+
+func NewMixerSetHandler(logger *slog.Logger) *MixerSetHandler {
+	return &MixerSetHandler{
+		logger: logger,
+	}
+}
+
+func NewMixerMuteHandler(muted bool, logger *slog.Logger) *MixerMuteHandler {
+	return &MixerMuteHandler{
+		muted:  muted,
+		logger: logger,
+	}
+}
+
+func (h *MixerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	cards, err := audio.MixerCards(r.Context())
+	if err != nil {
+		writeMixerError(w, r, err, "mixer listing failed", logger)
+		return
+	}
+
+	card, control := audio.DefaultMixer()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MixerResponse{
+		Cards:          cards,
+		DefaultCard:    card,
+		DefaultControl: control,
+		Timestamp:      time.Now().Format(time.RFC3339),
+	})
+}
+
+func (h *MixerControlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	card, name := r.PathValue("card"), r.PathValue("control")
+	control, err := audio.GetMixerControl(r.Context(), card, name)
+	if err != nil {
+		writeMixerError(w, r, err, "mixer control get failed", logger)
+		return
+	}
+	writeMixerControl(w, card, control)
+}
+
+func (h *MixerSetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	var req MixerSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("invalid request body", "error", err, "remote_addr", r.RemoteAddr)
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+	switch {
+	case req.Percent == nil && req.DB == nil && req.Balance == nil:
+		writeError(w, r, CodeInvalidRequest, "expected 'percent', 'db' or 'balance'")
+		return
+	case req.Percent != nil && req.DB != nil:
+		writeError(w, r, CodeInvalidRequest, "'percent' and 'db' are mutually exclusive")
+		return
+	case req.Percent != nil && (*req.Percent < 0 || *req.Percent > 100):
+		writeError(w, r, CodeInvalidRequest, "percent must be between 0 and 100")
+		return
+	case req.Balance != nil && (*req.Balance < -1 || *req.Balance > 1):
+		writeError(w, r, CodeInvalidRequest, "balance must be between -1 and 1")
+		return
+	}
+
+	card, name := r.PathValue("card"), r.PathValue("control")
+	var control audio.MixerControl
+	var err error
+	switch {
+	case req.Percent != nil:
+		control, err = audio.SetMixerPercent(r.Context(), card, name, *req.Percent)
+	case req.DB != nil:
+		control, err = audio.SetMixerDB(r.Context(), card, name, *req.DB)
+	}
+	if err == nil && req.Balance != nil {
+		control, err = audio.SetMixerBalance(r.Context(), card, name, *req.Balance)
+	}
+	if err != nil {
+		writeMixerError(w, r, err, "mixer set failed", logger)
+		return
+	}
+
+	logger.Info("mixer set",
+		"card", card,
+		"control", name,
+		"volume", control.Volume,
+		"balance", control.Balance,
+		"remote_addr", r.RemoteAddr,
+	)
+	writeMixerControl(w, card, control)
+}
+
+func (h *MixerMuteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	card, name := r.PathValue("card"), r.PathValue("control")
+	control, err := audio.SetMixerMute(r.Context(), card, name, h.muted)
+	if err != nil {
+		writeMixerError(w, r, err, "mixer mute failed", logger)
+		return
+	}
+
+	logger.Info("mixer mute set",
+		"card", card,
+		"control", name,
+		"muted", h.muted,
+		"remote_addr", r.RemoteAddr,
+	)
+	writeMixerControl(w, card, control)
+}
+
+func writeMixerControl(w http.ResponseWriter, card string, control audio.MixerControl) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MixerControlResponse{
+		Status:    "ok",
+		Card:      card,
+		Control:   control,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+func writeMixerError(w http.ResponseWriter, r *http.Request, err error, label string, logger *slog.Logger) {
+	code := CodeMixerUnavailable
+	switch {
+	case errors.Is(err, audio.ErrMixerNotFound):
+		code = CodeMixerNotFound
+	case errors.Is(err, audio.ErrMixerUnsupported):
+		code = CodeMixerUnsupported
+	}
+	logger.Error(label,
+		"error", err,
+		"remote_addr", r.RemoteAddr,
+	)
+	writeError(w, r, code, err.Error())
+}
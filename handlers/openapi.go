@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"jacadi/config"
+	"jacadi/logging"
+)
+
+//go:embed static/docs.html
+var docsPage []byte
+
+var pathParamRe = regexp.MustCompile(`\{([^}.]+)(?:\.\.\.)?\}`)
+
+var quietPaths = map[string]bool{
+	"/health": true,
+	"/status": true,
+}
+
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Query       []Parameter
+	Request     any
+	RequestType string
+	Responses   map[int]any
+}
+
+type BinaryResponse struct {
+	ContentTypes []string
+}
+
+type Parameter struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+type registeredRoute struct {
+	method string
+	path   string
+	op     Operation
+}
+
+type Router struct {
+	mux     *http.ServeMux
+	routes  []registeredRoute
+	version string
+	logger  *slog.Logger
+}
+
+func NewRouter(version string, logger *slog.Logger) *Router {
+	return &Router{
+		mux:     http.NewServeMux(),
+		version: version,
+		logger:  logger,
+	}
+}
+
+func (rt *Router) Handle(pattern string, handler http.Handler, op Operation, attrs ...any) {
+	rt.mux.Handle(pattern, handler)
+
+	method, path, _ := strings.Cut(pattern, " ")
+	path = strings.TrimSuffix(path, "{$}")
+	rt.routes = append(rt.routes, registeredRoute{method: method, path: path, op: op})
+
+	rt.logger.Info("registered route", append([]any{"pattern", pattern}, attrs...)...)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && quietPaths[r.URL.Path] {
+		rt.serveQuiet(w, r)
+		return
+	}
+
+	r, id, span := withRequestID(r, rt.logger)
+	defer span.End()
+	w.Header().Set(RequestIDHeader, id)
+	w.Header().Set(TraceparentHeader, span.Context().Traceparent())
+
+	status := accessLog(w, r, rt.mux)
+	if r.Pattern != "" {
+		route := r.Pattern
+		if _, path, ok := strings.Cut(route, " "); ok {
+			route = path
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes("http.route", route)
+	}
+	span.SetAttributes("http.response.status_code", status)
+	if status >= http.StatusInternalServerError {
+		span.SetError(errors.New(http.StatusText(status)))
+	}
+}
+
+func (rt *Router) serveQuiet(w http.ResponseWriter, r *http.Request) {
+	r, id := withUntracedRequestID(r, rt.logger)
+	w.Header().Set(RequestIDHeader, id)
+
+	rec := &statusRecorder{ResponseWriter: w}
+	rt.mux.ServeHTTP(rec, r)
+	if rec.status >= http.StatusBadRequest {
+		logging.FromContext(r.Context(), rt.logger).Warn("request failed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"remote_addr", r.RemoteAddr,
+		)
+	}
+}
+
+func (rt *Router) OpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(rt.OpenAPI())
+	})
+}
+
+func DocsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(docsPage)
+	})
+}
+
+func (rt *Router) OpenAPI() map[string]any {
+	schemas := make(map[string]any)
+	paths := make(map[string]map[string]any)
+
+	for _, route := range rt.routes {
+		item, ok := paths[route.path]
+		if !ok {
+			item = make(map[string]any)
+			paths[route.path] = item
+		}
+
+		op := map[string]any{
+			"operationId": operationID(route.method, route.path),
+			"responses":   responsesFor(route.op.Responses, schemas),
+		}
+		if route.op.Summary != "" {
+			op["summary"] = route.op.Summary
+		}
+		if route.op.Description != "" {
+			op["description"] = route.op.Description
+		}
+		if len(route.op.Tags) > 0 {
+			op["tags"] = route.op.Tags
+		}
+
+		var params []map[string]any
+		for _, m := range pathParamRe.FindAllStringSubmatch(route.path, -1) {
+			params = append(params, map[string]any{
+				"name":     m[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		for _, q := range route.op.Query {
+			param := map[string]any{
+				"name":     q.Name,
+				"in":       "query",
+				"required": q.Required,
+				"schema":   map[string]any{"type": "string"},
+			}
+			if q.Description != "" {
+				param["description"] = q.Description
+			}
+			params = append(params, param)
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if route.op.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": schemaRef(reflect.TypeOf(route.op.Request), schemas),
+					},
+				},
+			}
+		} else if route.op.RequestType != "" {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					route.op.RequestType: map[string]any{
+						"schema": map[string]any{"type": "string", "format": "binary"},
+					},
+				},
+			}
+		}
+
+		item[strings.ToLower(route.method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "jacadi",
+			"description": "HTTP API to play audio and TTS through a speaker",
+			"version":     rt.version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+func responsesFor(responses map[int]any, schemas map[string]any) map[string]any {
+	out := make(map[string]any)
+	for status, body := range responses {
+		resp := map[string]any{
+			"description": http.StatusText(status),
+		}
+		if binary, ok := body.(BinaryResponse); ok {
+			content := make(map[string]any)
+			for _, contentType := range binary.ContentTypes {
+				content[contentType] = map[string]any{
+					"schema": map[string]any{"type": "string", "format": "binary"},
+				}
+			}
+			resp["content"] = content
+		} else if body != nil {
+			resp["content"] = map[string]any{
+				"application/json": map[string]any{
+					"schema": schemaRef(reflect.TypeOf(body), schemas),
+				},
+			}
+		}
+		out[strconv.Itoa(status)] = resp
+	}
+	if len(out) == 0 {
+		out["200"] = map[string]any{"description": http.StatusText(http.StatusOK)}
+	}
+	return out
+}
+
+func schemaRef(t reflect.Type, schemas map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if t == reflect.TypeOf(config.Duration(0)) {
+		return map[string]any{
+			"type":        "string",
+			"format":      "duration",
+			"example":     "1.5s",
+			"description": "Go duration; a number of seconds is also accepted in requests",
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = map[string]any{}
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	collectFields(t, schemas, properties, &required)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func collectFields(t reflect.Type, schemas, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectFields(field.Type, schemas, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaRef(field.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '{' || r == '}' || r == '.'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -9,38 +9,78 @@ import (
 	"time"
 
 	"jacadi/audio"
+	"jacadi/config"
+	"jacadi/history"
+	"jacadi/logging"
 )
 
 type PlaybackHandler struct {
 	coordinator  *audio.Coordinator
+	device       string
+	command      string
 	audioPath    string
 	cmdType      string
+	folder       audio.FolderOptions
+	priority     int
 	deviceVolume *int
+	history      *history.Store
 	logger       *slog.Logger
 }
 
 type PlaybackResponse struct {
 	Status    string `json:"status"`
 	File      string `json:"file,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Session   string `json:"session,omitempty"`
 	Timestamp string `json:"timestamp"`
 }
 
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-	File    string `json:"file,omitempty"`
-}
-
-func NewPlaybackHandler(coordinator *audio.Coordinator, audioPath, cmdType string, deviceVolume *int, logger *slog.Logger) *PlaybackHandler {
+func NewPlaybackHandler(coordinator *audio.Coordinator, device, command, audioPath string, cmd config.Command, deviceVolume *int, store *history.Store, logger *slog.Logger) *PlaybackHandler {
 	return &PlaybackHandler{
 		coordinator:  coordinator,
+		device:       device,
+		command:      command,
 		audioPath:    audioPath,
-		cmdType:      cmdType,
+		cmdType:      cmd.Type,
+		folder:       folderOptions(device, command, cmd),
+		priority:     cmd.Priority,
 		deviceVolume: deviceVolume,
+		history:      store,
 		logger:       logger,
 	}
 }
 
+func folderOptions(device, command string, cmd config.Command) audio.FolderOptions {
+	fadeIn, fadeOut, trackFade := cmd.FolderFades()
+	return audio.FolderOptions{
+		Mode:        cmd.Mode,
+		Repeat:      cmd.Repeat,
+		Shuffle:     cmd.Shuffle,
+		Playlist:    cmd.GetPlaylistPath(device, command),
+		Glob:        cmd.Glob,
+		MaxDuration: time.Duration(cmd.MaxDuration),
+		FadeIn:      fadeIn,
+		FadeOut:     fadeOut,
+		TrackFade:   trackFade,
+	}
+}
+
+func (h *PlaybackHandler) record(remoteAddr, outcome string, err error, duration time.Duration) {
+	entry := history.Entry{
+		Timestamp:  time.Now().Add(-duration),
+		Device:     h.device,
+		Command:    h.command,
+		Volume:     h.deviceVolume,
+		RemoteAddr: remoteAddr,
+		Outcome:    outcome,
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	h.history.Record(entry)
+}
+
 func (h *PlaybackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if h.cmdType == "folder" {
 		h.serveFolder(w, r)
@@ -50,85 +90,86 @@ func (h *PlaybackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PlaybackHandler) serveFolder(w http.ResponseWriter, r *http.Request) {
-	if err := h.coordinator.PlayFolder(h.audioPath, h.deviceVolume); err != nil {
-		h.logger.Error("folder start failed",
+	logger := logging.FromContext(r.Context(), h.logger)
+	sessionID := FolderSessionID(h.device, h.command)
+	state, err := h.coordinator.PlayFolder(r.Context(), sessionID, h.audioPath, h.priority, h.folder, h.deviceVolume)
+	if err != nil {
+		logger.Error("folder start failed",
 			"error", err,
 			"path", h.audioPath,
 			"remote_addr", r.RemoteAddr,
 		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "folder failed",
-			Message: err.Error(),
-		})
+		h.record(r.RemoteAddr, history.OutcomeFailed, err, 0)
+		writeError(w, r, playbackErrorCode(err), err.Error())
 		return
 	}
 
-	h.logger.Info("folder started",
+	logger.Info("folder started",
 		"path", r.URL.Path,
 		"dir", h.audioPath,
+		"session", sessionID,
+		"state", state,
 		"remote_addr", r.RemoteAddr,
 	)
+	h.record(r.RemoteAddr, history.OutcomeStarted, nil, 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(PlaybackResponse{
-		Status:    "playing",
+		Status:    state,
+		Session:   sessionID,
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
 
 func (h *PlaybackHandler) serveAudio(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
 	filename := filepath.Base(h.audioPath)
 
 	if _, err := os.Stat(h.audioPath); err != nil {
 		if os.IsNotExist(err) {
-			h.logger.Error("audio file not found",
+			logger.Error("audio file not found",
 				"path", h.audioPath,
 				"file", filename,
 				"remote_addr", r.RemoteAddr,
 			)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(ErrorResponse{
-				Error: "audio file not found",
-				File:  filename,
-			})
+			h.record(r.RemoteAddr, history.OutcomeRejected, err, 0)
+			writeErrorResponse(w, r, ErrorResponse{Code: CodeAudioNotFound, File: filename})
 			return
 		}
 
-		h.logger.Error("error checking audio file",
+		logger.Error("error checking audio file",
 			"error", err,
 			"path", h.audioPath,
 			"remote_addr", r.RemoteAddr,
 		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "internal server error",
-			Message: "failed to access audio file",
-		})
+		h.record(r.RemoteAddr, history.OutcomeRejected, err, 0)
+		writeError(w, r, CodeInternal, "failed to access audio file")
 		return
 	}
 
-	if err := h.coordinator.PlayAsync(h.audioPath, h.deviceVolume); err != nil {
-		h.logger.Error("playback failed",
+	start := time.Now()
+	remoteAddr := r.RemoteAddr
+	done := func(err error) {
+		if err != nil {
+			h.record(remoteAddr, history.OutcomeFailed, err, time.Since(start))
+			return
+		}
+		h.record(remoteAddr, history.OutcomeCompleted, nil, time.Since(start))
+	}
+
+	if err := h.coordinator.PlayAsync(r.Context(), h.audioPath, h.deviceVolume, done); err != nil {
+		logger.Error("playback failed",
 			"error", err,
 			"path", h.audioPath,
 			"file", filename,
 			"remote_addr", r.RemoteAddr,
 		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "playback failed",
-			Message: err.Error(),
-		})
+		writeError(w, r, playbackErrorCode(err), err.Error())
 		return
 	}
 
-	h.logger.Info("audio playback started",
+	logger.Info("audio playback started",
 		"path", r.URL.Path,
 		"file", filename,
 		"remote_addr", r.RemoteAddr,
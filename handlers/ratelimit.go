@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jacadi/history"
+	"jacadi/logging"
+)
+
+const (
+	maxBuckets     = 1024
+	bucketIdleTTL  = 10 * time.Minute
+	maxDeviceProbe = 64 << 10
+)
+
+type DeviceKey func(r *http.Request) string
+
+type RateLimitConfig struct {
+	PerClient  int
+	PerToken   int
+	PerCommand int
+	Burst      int
+}
+
+type RateLimiter struct {
+	mu        sync.Mutex
+	cfg       RateLimitConfig
+	buckets   map[string]*bucket
+	cooldowns map[string]time.Time
+	history   *history.Store
+	logger    *slog.Logger
+}
+
+type bucket struct {
+	tokens float64
+	rate   float64
+	burst  float64
+	last   time.Time
+}
+
+func NewRateLimiter(cfg RateLimitConfig, store *history.Store, logger *slog.Logger) *RateLimiter {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return &RateLimiter{
+		cfg:       cfg,
+		buckets:   make(map[string]*bucket),
+		cooldowns: make(map[string]time.Time),
+		history:   store,
+		logger:    logger,
+	}
+}
+
+func (l *RateLimiter) Wrap(device, command string, cooldown time.Duration, next http.Handler) http.Handler {
+	return l.WrapFunc(func(*http.Request) string { return device }, command, cooldown, next)
+}
+
+func (l *RateLimiter) WrapFunc(deviceKey DeviceKey, command string, cooldown time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		device := deviceKey(r)
+		key := command
+		if device != "" {
+			key = device + "/" + command
+		}
+
+		wait, reason, until := l.allow(key, cooldown, r)
+		if wait > 0 {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			logging.FromContext(r.Context(), l.logger).Warn("request rate limited",
+				"key", key,
+				"reason", reason,
+				"retry_after", retryAfter,
+				"remote_addr", r.RemoteAddr,
+			)
+			l.history.Record(history.Entry{
+				Device:     device,
+				Command:    command,
+				RemoteAddr: r.RemoteAddr,
+				Outcome:    history.OutcomeRateLimited,
+				Error:      reason,
+			})
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, r, CodeRateLimited, fmt.Sprintf("%s limit exceeded, retry in %ds", reason, retryAfter))
+			return
+		}
+		if cooldown <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status != 0 && (rec.status < 200 || rec.status > 299) {
+			l.releaseCooldown(key, until)
+		}
+	})
+}
+
+func PathDevice(r *http.Request) string {
+	return r.PathValue("device")
+}
+
+func QueryDevice(r *http.Request) string {
+	return r.URL.Query().Get("device")
+}
+
+func BodyDevice(r *http.Request) string {
+	head, err := io.ReadAll(io.LimitReader(r.Body, maxDeviceProbe))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	var body struct {
+		Device string `json:"device"`
+	}
+	if json.Unmarshal(head, &body) != nil {
+		return ""
+	}
+	return body.Device
+}
+
+func (l *RateLimiter) releaseCooldown(key string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cooldowns[key].Equal(until) {
+		delete(l.cooldowns, key)
+	}
+}
+
+func (l *RateLimiter) allow(key string, cooldown time.Duration, r *http.Request) (time.Duration, string, time.Time) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cooldown > 0 {
+		if until, ok := l.cooldowns[key]; ok && now.Before(until) {
+			return until.Sub(now), "cooldown", time.Time{}
+		}
+	}
+
+	type check struct {
+		reason string
+		b      *bucket
+	}
+	var checks []check
+	if l.cfg.PerClient > 0 {
+		checks = append(checks, check{"client", l.bucketLocked("client:"+clientAddr(r), l.cfg.PerClient, now)})
+	}
+	if token := bearerToken(r); token != "" && l.cfg.PerToken > 0 {
+		checks = append(checks, check{"token", l.bucketLocked("token:"+token, l.cfg.PerToken, now)})
+	}
+	if l.cfg.PerCommand > 0 {
+		checks = append(checks, check{"command", l.bucketLocked("command:"+key, l.cfg.PerCommand, now)})
+	}
+
+	for _, c := range checks {
+		if wait := c.b.wait(now); wait > 0 {
+			return wait, c.reason, time.Time{}
+		}
+	}
+	for _, c := range checks {
+		c.b.tokens--
+	}
+
+	var until time.Time
+	if cooldown > 0 {
+		until = now.Add(cooldown)
+		l.cooldowns[key] = until
+	}
+	return 0, "", until
+}
+
+func (l *RateLimiter) bucketLocked(id string, perMinute int, now time.Time) *bucket {
+	b, ok := l.buckets[id]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.pruneLocked(now)
+		}
+		b = &bucket{
+			tokens: float64(l.cfg.Burst),
+			rate:   float64(perMinute) / 60,
+			burst:  float64(l.cfg.Burst),
+			last:   now,
+		}
+		l.buckets[id] = b
+	}
+	b.refill(now)
+	return b
+}
+
+func (l *RateLimiter) pruneLocked(now time.Time) {
+	var oldestID string
+	var oldest time.Time
+	for id, b := range l.buckets {
+		last := b.last
+		b.refill(now)
+		if b.tokens >= b.burst || now.Sub(last) > bucketIdleTTL {
+			delete(l.buckets, id)
+			continue
+		}
+		b.last = last
+		if oldestID == "" || last.Before(oldest) {
+			oldestID, oldest = id, last
+		}
+	}
+	if len(l.buckets) >= maxBuckets {
+		delete(l.buckets, oldestID)
+	}
+	for key, until := range l.cooldowns {
+		if now.After(until) {
+			delete(l.cooldowns, key)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+func (b *bucket) wait(now time.Time) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(cfg RateLimitConfig) *RateLimiter {
+	return NewRateLimiter(cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func limitedRequest(remoteAddr, token string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/play/dreame/chime", nil)
+	r.RemoteAddr = remoteAddr
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	type attempt struct {
+		remoteAddr string
+		token      string
+		key        string
+		wantReason string
+	}
+
+	tests := []struct {
+		name     string
+		cfg      RateLimitConfig
+		cooldown time.Duration
+		attempts []attempt
+	}{
+		{
+			name:     "unlimited",
+			attempts: []attempt{{key: "dreame/chime"}, {key: "dreame/chime"}, {key: "dreame/chime"}},
+		},
+		{
+			name:     "cooldown",
+			cooldown: time.Minute,
+			attempts: []attempt{{key: "dreame/chime"}, {key: "dreame/chime", wantReason: "cooldown"}, {key: "dreame/bell"}},
+		},
+		{
+			name: "client burst",
+			cfg:  RateLimitConfig{PerClient: 1, Burst: 2},
+			attempts: []attempt{
+				{remoteAddr: "10.0.0.1:1000", key: "dreame/chime"},
+				{remoteAddr: "10.0.0.1:1001", key: "dreame/bell"},
+				{remoteAddr: "10.0.0.1:1002", key: "dreame/chime", wantReason: "client"},
+				{remoteAddr: "10.0.0.2:1000", key: "dreame/chime"},
+			},
+		},
+		{
+			name: "token",
+			cfg:  RateLimitConfig{PerToken: 1},
+			attempts: []attempt{
+				{remoteAddr: "10.0.0.1:1000", token: "secret", key: "dreame/chime"},
+				{remoteAddr: "10.0.0.2:1000", token: "secret", key: "dreame/chime", wantReason: "token"},
+				{remoteAddr: "10.0.0.2:1000", token: "other", key: "dreame/chime"},
+				{remoteAddr: "10.0.0.2:1000", key: "dreame/chime"},
+			},
+		},
+		{
+			name: "command",
+			cfg:  RateLimitConfig{PerCommand: 1},
+			attempts: []attempt{
+				{remoteAddr: "10.0.0.1:1000", key: "dreame/chime"},
+				{remoteAddr: "10.0.0.2:1000", key: "dreame/chime", wantReason: "command"},
+				{remoteAddr: "10.0.0.2:1000", key: "kitchen/chime"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newTestLimiter(tt.cfg)
+			for i, a := range tt.attempts {
+				remoteAddr := a.remoteAddr
+				if remoteAddr == "" {
+					remoteAddr = "10.0.0.1:1000"
+				}
+				wait, reason, _ := limiter.allow(a.key, tt.cooldown, limitedRequest(remoteAddr, a.token))
+				if reason != a.wantReason {
+					t.Errorf("attempt %d: allow() reason = %q, want %q", i, reason, a.wantReason)
+				}
+				if (wait > 0) != (a.wantReason != "") {
+					t.Errorf("attempt %d: allow() wait = %s with reason %q", i, wait, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterCooldownRelease(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantSecond int
+	}{
+		{name: "success keeps the cooldown", status: http.StatusOK, wantSecond: http.StatusTooManyRequests},
+		{name: "failure releases the cooldown", status: http.StatusNotFound, wantSecond: http.StatusNotFound},
+		{name: "server error releases the cooldown", status: http.StatusInternalServerError, wantSecond: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newTestLimiter(RateLimitConfig{})
+			handler := limiter.Wrap("dreame", "chime", time.Minute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, limitedRequest("10.0.0.1:1000", ""))
+			if rec.Code != tt.status {
+				t.Fatalf("first request status = %d, want %d", rec.Code, tt.status)
+			}
+
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, limitedRequest("10.0.0.1:1000", ""))
+			if rec.Code != tt.wantSecond {
+				t.Errorf("second request status = %d, want %d", rec.Code, tt.wantSecond)
+			}
+			if tt.wantSecond == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "60" {
+				t.Errorf("Retry-After = %q, want %q", rec.Header().Get("Retry-After"), "60")
+			}
+		})
+	}
+}
+
+func TestRateLimiterCooldownExpires(t *testing.T) {
+	limiter := newTestLimiter(RateLimitConfig{})
+	r := limitedRequest("10.0.0.1:1000", "")
+
+	if wait, _, _ := limiter.allow("dreame/chime", 20*time.Millisecond, r); wait > 0 {
+		t.Fatalf("allow() wait = %s, want the first request accepted", wait)
+	}
+	if wait, _, _ := limiter.allow("dreame/chime", 20*time.Millisecond, r); wait <= 0 {
+		t.Fatalf("allow() accepted a request during the cooldown")
+	}
+	time.Sleep(25 * time.Millisecond)
+	if wait, reason, _ := limiter.allow("dreame/chime", 20*time.Millisecond, r); wait > 0 {
+		t.Errorf("allow() after the cooldown = %s (%s), want the request accepted", wait, reason)
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	limiter := newTestLimiter(RateLimitConfig{PerClient: 60, Burst: 2})
+	for i := range maxBuckets + 10 {
+		r := limitedRequest(fmt.Sprintf("10.0.%d.%d:1000", i/256, i%256), "")
+		limiter.allow("dreame/chime", 0, r)
+	}
+	if got := len(limiter.buckets); got > maxBuckets {
+		t.Errorf("len(buckets) = %d, want at most %d", got, maxBuckets)
+	}
+}
+
+func TestBodyDevice(t *testing.T) {
+	body := `{"device": "kitchen", "text": "Hello"}`
+	r := httptest.NewRequest(http.MethodPost, "/play/tts", strings.NewReader(body))
+
+	if got := BodyDevice(r); got != "kitchen" {
+		t.Errorf("BodyDevice() = %q, want %q", got, "kitchen")
+	}
+	rest, _ := io.ReadAll(r.Body)
+	if string(rest) != body {
+		t.Errorf("body after BodyDevice() = %q, want %q", rest, body)
+	}
+}
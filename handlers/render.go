@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"jacadi/audio"
+	"jacadi/config"
+	"jacadi/logging"
+	"jacadi/tts"
+)
+
+type RenderHandler struct {
+	deviceConfig config.DeviceConfig
+	engines      *tts.Registry
+	cache        *tts.Cache
+	logger       *slog.Logger
+}
+
+type RenderTTSHandler struct {
+	engines      *tts.Registry
+	cache        *tts.Cache
+	deviceConfig config.DeviceConfig
+	logger       *slog.Logger
+}
+
+func NewRenderHandler(deviceConfig config.DeviceConfig, engines *tts.Registry, cache *tts.Cache, logger *slog.Logger) *RenderHandler {
+	return &RenderHandler{
+		deviceConfig: deviceConfig,
+		engines:      engines,
+		cache:        cache,
+		logger:       logger,
+	}
+}
+
+func NewRenderTTSHandler(engines *tts.Registry, cache *tts.Cache, deviceConfig config.DeviceConfig, logger *slog.Logger) *RenderTTSHandler {
+	return &RenderTTSHandler{
+		engines:      engines,
+		cache:        cache,
+		deviceConfig: deviceConfig,
+		logger:       logger,
+	}
+}
+
+func (h *RenderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	deviceName, commandName := r.PathValue("device"), r.PathValue("command")
+	device, ok := lookupDevice(w, r, h.deviceConfig, deviceName)
+	if !ok {
+		return
+	}
+
+	cmd, ok := device.Commands[commandName]
+	if !ok {
+		writeError(w, r, CodeCommandNotFound, fmt.Sprintf("no command named %q on %s", commandName, deviceName))
+		return
+	}
+
+	var wav []byte
+	var err error
+	code := CodeInternal
+	switch cmd.Type {
+	case "":
+		wav, err = readAsWav(cmd.GetAudioPath(deviceName, commandName))
+		if os.IsNotExist(err) {
+			code = CodeAudioNotFound
+		}
+	case "template":
+		wav, err = h.renderTemplate(r, commandName, cmd, device)
+		switch {
+		case errors.Is(err, errTTSUnavailable):
+			code = CodeTTSUnavailable
+		case errors.Is(err, errTemplate):
+			code = CodeTemplateInvalid
+		case errors.Is(err, tts.ErrUnknownEngine):
+			code = CodeTTSEngineUnknown
+		default:
+			code = ttsErrorCode(err)
+		}
+	default:
+		err = fmt.Errorf("%s commands cannot be rendered", cmd.Type)
+		code = CodeInvalidRequest
+	}
+	if err != nil {
+		logger.Error("render failed",
+			"error", err,
+			"device", deviceName,
+			"command", commandName,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, code, err.Error())
+		return
+	}
+
+	writeAudio(w, r, wav, commandName, logger)
+}
+
+var (
+	errTTSUnavailable = errors.New("TTS is not available")
+	errTemplate       = errors.New("template error")
+)
+
+func (h *RenderHandler) renderTemplate(r *http.Request, name string, cmd config.Command, device config.Device) ([]byte, error) {
+	if h.cache == nil {
+		return nil, errTTSUnavailable
+	}
+
+	tmpl, err := cmd.ParseTemplate(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errTemplate, err)
+	}
+	params := make(map[string]any)
+	for key, values := range r.URL.Query() {
+		if key != "format" {
+			params[key] = values[len(values)-1]
+		}
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, params); err != nil {
+		return nil, fmt.Errorf("%w: %v", errTemplate, err)
+	}
+
+	engine, err := h.engines.Get(device.TTSEngine)
+	if err != nil {
+		return nil, err
+	}
+	wav, _, err := h.cache.Render(r.Context(), engine, tts.Request{Text: text.String(), Voice: device.Voice})
+	return wav, err
+}
+
+func (h *RenderTTSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	r.Body = http.MaxBytesReader(w, r.Body, maxSpeechBodySize)
+	var req TTSRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("invalid request body",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	engine, voice, ok := resolveSpeech(w, r, h.engines, h.deviceConfig, req, logger)
+	if !ok {
+		return
+	}
+
+	wav, err := h.synthesize(r.Context(), engine, req.speech(engine, voice))
+	if err != nil {
+		logger.Error("TTS failed",
+			"error", err,
+			"engine", engine.Name(),
+			"voice", voice,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, ttsErrorCode(err), err.Error())
+		return
+	}
+
+	writeAudio(w, r, wav, "tts", logger)
+}
+
+func (h *RenderTTSHandler) synthesize(ctx context.Context, engine tts.Engine, req tts.Request) ([]byte, error) {
+	if h.cache != nil {
+		wav, _, err := h.cache.Render(ctx, engine, req)
+		return wav, err
+	}
+
+	result, err := tts.Synthesize(ctx, engine, req)
+	if err != nil {
+		return nil, err
+	}
+	return audio.EncodeWav(result.PCM, result.SampleRate, result.Channels), nil
+}
+
+func readAsWav(path string) ([]byte, error) {
+	if audio.IsWav(path) {
+		return os.ReadFile(path)
+	}
+	pcm, err := audio.DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return audio.EncodeWav(pcm.Data, pcm.SampleRate, pcm.Channels), nil
+}
+
+func requestedFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "audio/ogg"):
+		return "ogg"
+	case strings.Contains(accept, "audio/mpeg"):
+		return "mp3"
+	default:
+		return "wav"
+	}
+}
+
+func writeAudio(w http.ResponseWriter, r *http.Request, wav []byte, name string, logger *slog.Logger) {
+	format := requestedFormat(r)
+	data, err := audio.Encode(wav, format)
+	if err != nil {
+		logger.Error("audio encoding failed",
+			"error", err,
+			"format", format,
+			"remote_addr", r.RemoteAddr,
+		)
+		code := CodeInternal
+		switch {
+		case errors.Is(err, audio.ErrUnsupportedFormat):
+			code = CodeFormatUnsupported
+		case errors.Is(err, audio.ErrEncoderUnavailable):
+			code = CodeEncoderUnavailable
+		}
+		writeError(w, r, code, err.Error())
+		return
+	}
+
+	contentType, _ := audio.ContentType(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name+"."+format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
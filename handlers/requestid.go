@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"jacadi/logging"
+	"jacadi/tracing"
+)
+
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceparentHeader = "traceparent"
+)
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+func requestLogger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context(), slog.Default())
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func incomingRequestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+func withUntracedRequestID(r *http.Request, logger *slog.Logger) (*http.Request, string) {
+	id := incomingRequestID(r)
+	ctx := context.WithValue(r.Context(), requestIDKey, id)
+	ctx = logging.WithLogger(ctx, logger.With("request_id", id))
+	return r.WithContext(ctx), id
+}
+
+func withRequestID(r *http.Request, logger *slog.Logger) (*http.Request, string, *tracing.Span) {
+	id := incomingRequestID(r)
+
+	remote, _ := tracing.ParseTraceparent(r.Header.Get(TraceparentHeader))
+	ctx, span := tracing.StartServer(r.Context(), r.Method,
+		remote,
+		"http.request.method", r.Method,
+		"url.path", r.URL.Path,
+		"client.address", r.RemoteAddr,
+		"request_id", id,
+	)
+
+	ctx = context.WithValue(ctx, requestIDKey, id)
+	ctx = logging.WithLogger(ctx, logger.With("request_id", id, "trace_id", span.Context().TraceID))
+	return r.WithContext(ctx), id, span
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/logging"
+	"jacadi/tts"
+)
+
+type StatusHandler struct {
+	coordinator *audio.Coordinator
+	engines     *tts.Registry
+	logger      *slog.Logger
+}
+
+type StatusResponse struct {
+	audio.Status
+	Volume           *int     `json:"volume,omitempty"`
+	TTSEnabled       bool     `json:"tts_enabled"`
+	TTSEngines       []string `json:"tts_engines,omitempty"`
+	DefaultTTSEngine string   `json:"default_tts_engine,omitempty"`
+	DefaultVoice     string   `json:"default_voice,omitempty"`
+	Timestamp        string   `json:"timestamp"`
+}
+
+func NewStatusHandler(coordinator *audio.Coordinator, engines *tts.Registry, logger *slog.Logger) *StatusHandler {
+	return &StatusHandler{
+		coordinator: coordinator,
+		engines:     engines,
+		logger:      logger,
+	}
+}
+
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	response := StatusResponse{
+		Status:     h.coordinator.Status(),
+		TTSEnabled: h.engines.Len() > 0,
+		Timestamp:  time.Now().Format(time.RFC3339),
+	}
+	if engine, err := h.engines.Get(""); err == nil {
+		response.TTSEngines = h.engines.Names()
+		response.DefaultTTSEngine = engine.Name()
+		response.DefaultVoice = engine.DefaultVoice()
+	}
+
+	if volume, err := audio.GetVolume(r.Context()); err != nil {
+		logger.Warn("volume get failed", "error", err, "remote_addr", r.RemoteAddr)
+	} else {
+		response.Volume = &volume
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
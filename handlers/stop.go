@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"jacadi/audio"
+	"jacadi/logging"
 )
 
 type StopHandler struct {
@@ -22,9 +23,10 @@ func NewStopHandler(coordinator *audio.Coordinator, logger *slog.Logger) *StopHa
 }
 
 func (h *StopHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
 	h.coordinator.StopFolder()
 
-	h.logger.Info("folder stopped", "remote_addr", r.RemoteAddr)
+	logger.Info("folder stopped", "remote_addr", r.RemoteAddr)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"jacadi/audio"
+	"jacadi/config"
+	"jacadi/history"
+	"jacadi/logging"
+	"jacadi/tts"
+)
+
+const maxTemplateBodySize = 64 << 10
+
+type TemplateHandler struct {
+	coordinator *audio.Coordinator
+	engines     *tts.Registry
+	cache       *tts.Cache
+	device      string
+	command     string
+	template    *template.Template
+	deviceCfg   config.Device
+	history     *history.Store
+	logger      *slog.Logger
+}
+
+func NewTemplateHandler(coordinator *audio.Coordinator, engines *tts.Registry, cache *tts.Cache, device, command string, cmd config.Command, deviceCfg config.Device, store *history.Store, logger *slog.Logger) (*TemplateHandler, error) {
+	tmpl, err := cmd.ParseTemplate(command)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateHandler{
+		coordinator: coordinator,
+		engines:     engines,
+		cache:       cache,
+		device:      device,
+		command:     command,
+		template:    tmpl,
+		deviceCfg:   deviceCfg,
+		history:     store,
+		logger:      logger,
+	}, nil
+}
+
+func (h *TemplateHandler) record(entry history.Entry, outcome string, err error, duration time.Duration) {
+	entry.Timestamp = time.Now().Add(-duration)
+	entry.Outcome = outcome
+	entry.DurationMs = duration.Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	h.history.Record(entry)
+}
+
+func (h *TemplateHandler) params(r *http.Request) (map[string]any, error) {
+	params := make(map[string]any)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxTemplateBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, values := range r.URL.Query() {
+		params[key] = values[len(values)-1]
+	}
+	return params, nil
+}
+
+func (h *TemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	entry := history.Entry{
+		Device:     h.device,
+		Command:    h.command,
+		Volume:     h.deviceCfg.Volume,
+		RemoteAddr: r.RemoteAddr,
+	}
+
+	params, err := h.params(r)
+	if err != nil {
+		logger.Error("invalid request body",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		h.record(entry, history.OutcomeRejected, err, 0)
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	var text strings.Builder
+	if err := h.template.Execute(&text, params); err != nil {
+		logger.Error("template rendering failed",
+			"error", err,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+		)
+		h.record(entry, history.OutcomeRejected, err, 0)
+		writeError(w, r, CodeTemplateInvalid, err.Error())
+		return
+	}
+	entry.TextHash = history.HashText(text.String())
+
+	engine, err := h.engines.Get(h.deviceCfg.TTSEngine)
+	if err != nil {
+		logger.Error("unknown TTS engine",
+			"engine", h.deviceCfg.TTSEngine,
+			"remote_addr", r.RemoteAddr,
+		)
+		h.record(entry, history.OutcomeRejected, err, 0)
+		writeError(w, r, CodeTTSUnavailable, err.Error())
+		return
+	}
+	voice := h.deviceCfg.Voice
+	if voice == "" {
+		voice = engine.DefaultVoice()
+	}
+	entry.Engine, entry.Voice = engine.Name(), voice
+
+	start := time.Now()
+	wav, cached, err := h.cache.Render(r.Context(), engine, tts.Request{Text: text.String(), Voice: voice})
+	var pcm audio.PCM
+	if err == nil {
+		pcm, err = audio.Decode(wav)
+	}
+	if err != nil {
+		logger.Error("TTS synthesis failed",
+			"error", err,
+			"engine", engine.Name(),
+			"voice", voice,
+			"remote_addr", r.RemoteAddr,
+		)
+		h.record(entry, history.OutcomeFailed, err, time.Since(start))
+		writeError(w, r, ttsErrorCode(err), err.Error())
+		return
+	}
+
+	done := func(err error) {
+		if err != nil {
+			h.record(entry, history.OutcomeFailed, err, time.Since(start))
+			return
+		}
+		h.record(entry, history.OutcomeCompleted, nil, time.Since(start))
+	}
+
+	if err := h.coordinator.PlayPCMAsync(r.Context(), pcm, h.deviceCfg.Volume, done); err != nil {
+		logger.Error("playback failed",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, playbackErrorCode(err), err.Error())
+		return
+	}
+
+	logger.Info("template playback started",
+		"path", r.URL.Path,
+		"engine", engine.Name(),
+		"voice", voice,
+		"cached", cached,
+		"remote_addr", r.RemoteAddr,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PlaybackResponse{
+		Status:    "playing",
+		Text:      text.String(),
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
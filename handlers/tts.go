@@ -2,82 +2,217 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
+	"jacadi/config"
+	"jacadi/history"
+	"jacadi/logging"
 	"jacadi/tts"
 )
 
+const maxSpeechBodySize = 1 << 20
+
 type TTSHandler struct {
-	speaker tts.Speaker
-	logger  *slog.Logger
+	speaker      tts.Speaker
+	engines      *tts.Registry
+	deviceConfig config.DeviceConfig
+	history      *history.Store
+	logger       *slog.Logger
 }
 
 type TTSRequest struct {
-	Text  string `json:"text"`
-	Voice string `json:"voice,omitempty"`
+	Text            string  `json:"text"`
+	Voice           string  `json:"voice,omitempty"`
+	Engine          string  `json:"engine,omitempty"`
+	Device          string  `json:"device,omitempty"`
+	Speed           float64 `json:"speed,omitempty"`
+	Pitch           float64 `json:"pitch,omitempty"`
+	Noise           float64 `json:"noise,omitempty"`
+	NoiseW          float64 `json:"noise_w,omitempty"`
+	SentenceSilence float64 `json:"sentence_silence,omitempty"`
+}
+
+func (r TTSRequest) validate() error {
+	if r.Speed != 0 && (r.Speed < 0.25 || r.Speed > 4) {
+		return fmt.Errorf("speed must be between 0.25 and 4")
+	}
+	if r.Pitch < 0 || r.Pitch > 2 {
+		return fmt.Errorf("pitch must be between 0 and 2")
+	}
+	if r.Noise < 0 || r.Noise > 2 {
+		return fmt.Errorf("noise must be between 0 and 2")
+	}
+	if r.NoiseW < 0 || r.NoiseW > 2 {
+		return fmt.Errorf("noise_w must be between 0 and 2")
+	}
+	if r.SentenceSilence < 0 || r.SentenceSilence > 10 {
+		return fmt.Errorf("sentence_silence must be between 0 and 10 seconds")
+	}
+	return tts.CheckSilence(r.Text, time.Duration(r.SentenceSilence*float64(time.Second)))
 }
 
 type TTSResponse struct {
 	Status    string `json:"status"`
+	Engine    string `json:"engine"`
 	Voice     string `json:"voice"`
 	Timestamp string `json:"timestamp"`
 }
 
-func NewTTSHandler(speaker tts.Speaker, logger *slog.Logger) *TTSHandler {
+func NewTTSHandler(speaker tts.Speaker, engines *tts.Registry, deviceConfig config.DeviceConfig, store *history.Store, logger *slog.Logger) *TTSHandler {
 	return &TTSHandler{
-		speaker: speaker,
-		logger:  logger,
+		speaker:      speaker,
+		engines:      engines,
+		deviceConfig: deviceConfig,
+		history:      store,
+		logger:       logger,
+	}
+}
+
+func (r TTSRequest) speech(engine tts.Engine, voice string) tts.Request {
+	return tts.Request{
+		Text:   r.Text,
+		Voice:  voice,
+		Engine: engine.Name(),
+		Prosody: tts.Prosody{
+			Speed:  r.Speed,
+			Pitch:  r.Pitch,
+			Noise:  r.Noise,
+			NoiseW: r.NoiseW,
+		},
+		SentenceSilence: time.Duration(r.SentenceSilence * float64(time.Second)),
+	}
+}
+
+func resolveSpeech(w http.ResponseWriter, r *http.Request, engines *tts.Registry, deviceConfig config.DeviceConfig, req TTSRequest, logger *slog.Logger) (tts.Engine, string, bool) {
+	if req.Text == "" {
+		logger.Error("empty text in request",
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInvalidRequest, "text field cannot be empty")
+		return nil, "", false
+	}
+
+	if err := req.validate(); err != nil {
+		logger.Error("invalid TTS parameters",
+			"error", err,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return nil, "", false
+	}
+
+	engineName, voice := req.Engine, req.Voice
+	if req.Device != "" {
+		device, ok := lookupDevice(w, r, deviceConfig, req.Device)
+		if !ok {
+			return nil, "", false
+		}
+		if engineName == "" {
+			engineName = device.TTSEngine
+		}
+		if voice == "" {
+			voice = device.Voice
+		}
+	}
+
+	engine, err := engines.Get(engineName)
+	if err != nil {
+		logger.Error("unknown TTS engine",
+			"engine", engineName,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeTTSEngineUnknown, err.Error())
+		return nil, "", false
+	}
+	if req.Pitch > 0 && !tts.SupportsPitch(engine) {
+		logger.Error("pitch not supported",
+			"engine", engine.Name(),
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, CodeInvalidRequest, "pitch not supported by engine "+engine.Name())
+		return nil, "", false
+	}
+	if voice == "" {
+		voice = engine.DefaultVoice()
 	}
+
+	if err := engine.ValidateVoice(voice); err != nil {
+		logger.Error("voice lookup failed",
+			"error", err,
+			"engine", engine.Name(),
+			"voice", voice,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, ttsErrorCode(err), err.Error())
+		return nil, "", false
+	}
+
+	return engine, voice, true
 }
 
 func (h *TTSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	r.Body = http.MaxBytesReader(w, r.Body, maxSpeechBodySize)
 	var req TTSRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Error("invalid request body",
+		logger.Error("invalid request body",
 			"error", err,
 			"remote_addr", r.RemoteAddr,
 		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "invalid request body",
-			Message: err.Error(),
-		})
+		writeError(w, r, CodeInvalidRequest, err.Error())
 		return
 	}
 
-	if req.Text == "" {
-		h.logger.Error("empty text in request",
-			"remote_addr", r.RemoteAddr,
-		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "text is required",
-			Message: "text field cannot be empty",
-		})
+	engine, voice, ok := resolveSpeech(w, r, h.engines, h.deviceConfig, req, logger)
+	if !ok {
 		return
 	}
 
-	if err := h.speaker.SpeakAsync(req.Text, req.Voice); err != nil {
-		h.logger.Error("TTS failed",
+	start := time.Now()
+	entry := history.Entry{
+		Timestamp:  start,
+		Device:     req.Device,
+		Command:    "tts",
+		TextHash:   history.HashText(req.Text),
+		Engine:     engine.Name(),
+		Voice:      voice,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if device, ok := h.deviceConfig[req.Device]; ok {
+		entry.Volume = device.Volume
+	}
+
+	done := func(err error) {
+		entry.DurationMs = time.Since(start).Milliseconds()
+		if err != nil {
+			entry.Outcome = history.OutcomeFailed
+			entry.Error = err.Error()
+		} else {
+			entry.Outcome = history.OutcomeCompleted
+		}
+		h.history.Record(entry)
+	}
+
+	if err := h.speaker.SpeakAsync(r.Context(), req.speech(engine, voice), entry.Volume, done); err != nil {
+		logger.Error("TTS failed",
 			"error", err,
-			"voice", req.Voice,
+			"engine", engine.Name(),
+			"voice", voice,
 			"remote_addr", r.RemoteAddr,
 		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "TTS failed",
-			Message: err.Error(),
-		})
+		entry.Outcome = history.OutcomeRejected
+		entry.Error = err.Error()
+		h.history.Record(entry)
+		writeError(w, r, ttsErrorCode(err), err.Error())
 		return
 	}
 
-	h.logger.Info("TTS started",
-		"voice", req.Voice,
+	logger.Info("TTS started",
+		"engine", engine.Name(),
+		"voice", voice,
 		"text_length", len(req.Text),
 		"remote_addr", r.RemoteAddr,
 	)
@@ -86,7 +221,8 @@ func (h *TTSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(TTSResponse{
 		Status:    "speaking",
-		Voice:     req.Voice,
+		Engine:    engine.Name(),
+		Voice:     voice,
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
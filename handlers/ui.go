@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed static/index.html
+var indexPage []byte
+
+func UIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(indexPage)
+	})
+}
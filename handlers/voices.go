@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"jacadi/config"
+	"jacadi/logging"
+	"jacadi/tts"
+)
+
+const maxVoiceUploadSize = 512 << 20
+
+type VoicesHandler struct {
+	catalog *tts.VoiceCatalog
+	logger  *slog.Logger
+}
+
+type VoicesResponse struct {
+	Voices       []tts.Voice `json:"voices"`
+	DefaultVoice string      `json:"default_voice"`
+}
+
+type VoiceInstallRequest struct {
+	Path string `json:"path"`
+}
+
+func NewVoicesHandler(catalog *tts.VoiceCatalog, logger *slog.Logger) *VoicesHandler {
+	return &VoicesHandler{
+		catalog: catalog,
+		logger:  logger,
+	}
+}
+
+func (h *VoicesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	voices, err := h.catalog.List()
+	if err != nil {
+		logger.Error("voice listing failed", "error", err, "remote_addr", r.RemoteAddr)
+		writeError(w, r, CodeInternal, err.Error())
+		return
+	}
+	if voices == nil {
+		voices = []tts.Voice{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(VoicesResponse{
+		Voices:       voices,
+		DefaultVoice: config.GetDefaultVoice(),
+	})
+}
+
+type VoiceInstallHandler struct {
+	catalog *tts.VoiceCatalog
+	logger  *slog.Logger
+}
+
+type VoiceDeleteHandler struct {
+	catalog *tts.VoiceCatalog
+	logger  *slog.Logger
+}
+
+func NewVoiceInstallHandler(catalog *tts.VoiceCatalog, logger *slog.Logger) *VoiceInstallHandler {
+	return &VoiceInstallHandler{
+		catalog: catalog,
+		logger:  logger,
+	}
+}
+
+func (h *VoiceInstallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	name := r.PathValue("name")
+	overwrite, _ := strconv.ParseBool(r.URL.Query().Get("overwrite"))
+	r.Body = http.MaxBytesReader(w, r.Body, maxVoiceUploadSize)
+
+	var voice tts.Voice
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var req VoiceInstallRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			writeError(w, r, CodeInvalidRequest, "expected JSON with 'path' field pointing to a local tarball")
+			return
+		}
+		voice, err = h.catalog.InstallFile(name, req.Path, overwrite)
+	case "multipart/form-data":
+		var file io.ReadCloser
+		file, _, err = r.FormFile("file")
+		if err != nil {
+			writeError(w, r, CodeInvalidRequest, "expected multipart form with a 'file' field")
+			return
+		}
+		defer file.Close()
+		voice, err = h.catalog.Install(name, file, overwrite)
+	default:
+		voice, err = h.catalog.Install(name, r.Body, overwrite)
+	}
+
+	if err != nil {
+		code := CodeVoiceInstallFailed
+		if errors.Is(err, tts.ErrVoiceExists) {
+			code = CodeVoiceExists
+		}
+		logger.Error("voice install failed",
+			"error", err,
+			"voice", name,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, code, err.Error())
+		return
+	}
+
+	logger.Info("voice installed",
+		"voice", voice.Name,
+		"remote_addr", r.RemoteAddr,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(voice)
+}
+
+func NewVoiceDeleteHandler(catalog *tts.VoiceCatalog, logger *slog.Logger) *VoiceDeleteHandler {
+	return &VoiceDeleteHandler{
+		catalog: catalog,
+		logger:  logger,
+	}
+}
+
+func (h *VoiceDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	name := r.PathValue("name")
+	if err := h.catalog.Delete(name); err != nil {
+		code := CodeInternal
+		if errors.Is(err, tts.ErrUnknownVoice) {
+			code = CodeTTSVoiceUnknown
+		}
+		logger.Error("voice delete failed",
+			"error", err,
+			"voice", name,
+			"remote_addr", r.RemoteAddr,
+		)
+		writeError(w, r, code, err.Error())
+		return
+	}
+
+	logger.Info("voice deleted",
+		"voice", name,
+		"remote_addr", r.RemoteAddr,
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,81 +2,147 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"jacadi/audio"
+	"jacadi/logging"
+)
+
+const (
+	VolumeStepUp   = "up"
+	VolumeStepDown = "down"
 )
 
 type VolumeHandler struct {
+	step   int
+	logger *slog.Logger
+}
+
+type VolumeMuteHandler struct {
+	muted  bool
 	logger *slog.Logger
 }
 
 type VolumeRequest struct {
-	Volume int `json:"volume"`
+	Volume *int   `json:"volume,omitempty"`
+	Delta  *int   `json:"delta,omitempty"`
+	Step   string `json:"step,omitempty"`
+	Mute   *bool  `json:"mute,omitempty"`
 }
 
 type VolumeResponse struct {
 	Status    string `json:"status"`
 	Volume    int    `json:"volume"`
+	Muted     bool   `json:"muted"`
 	Timestamp string `json:"timestamp"`
 }
 
-func NewVolumeHandler(logger *slog.Logger) *VolumeHandler {
+func NewVolumeHandler(step int, logger *slog.Logger) *VolumeHandler {
 	return &VolumeHandler{
+		step:   step,
 		logger: logger,
 	}
 }
 
+func NewVolumeMuteHandler(muted bool, logger *slog.Logger) *VolumeMuteHandler {
+	return &VolumeMuteHandler{
+		muted:  muted,
+		logger: logger,
+	}
+}
+
+func (h *VolumeHandler) delta(req VolumeRequest) (int, bool, error) {
+	switch {
+	case req.Delta != nil:
+		return *req.Delta, true, nil
+	case req.Step == VolumeStepUp:
+		return h.step, true, nil
+	case req.Step == VolumeStepDown:
+		return -h.step, true, nil
+	case req.Step != "":
+		return 0, false, fmt.Errorf("step must be %q or %q", VolumeStepUp, VolumeStepDown)
+	}
+	return 0, false, nil
+}
+
 func (h *VolumeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
 	var req VolumeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Error("invalid request body", "error", err, "remote_addr", r.RemoteAddr)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "invalid request",
-			Message: "expected JSON with 'volume' field",
-		})
+		logger.Error("invalid request body", "error", err, "remote_addr", r.RemoteAddr)
+		writeError(w, r, CodeInvalidRequest, "expected JSON with 'volume', 'delta', 'step' or 'mute' field")
 		return
 	}
 
-	volume := req.Volume
-	if volume < 0 {
-		volume = 0
+	set := 0
+	for _, ok := range []bool{req.Volume != nil, req.Delta != nil, req.Step != ""} {
+		if ok {
+			set++
+		}
 	}
-	if volume > 100 {
-		volume = 100
+	switch {
+	case set == 0 && req.Mute == nil:
+		writeError(w, r, CodeInvalidRequest, "expected JSON with 'volume', 'delta', 'step' or 'mute' field")
+		return
+	case set > 1:
+		writeError(w, r, CodeInvalidRequest, "'volume', 'delta' and 'step' are mutually exclusive")
+		return
 	}
 
-	if err := audio.SetVolume(volume); err != nil {
-		h.logger.Error("volume set failed",
-			"error", err,
+	delta, relative, err := h.delta(req)
+	if err != nil {
+		writeError(w, r, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if req.Volume != nil || relative {
+		volume := 0
+		if req.Volume != nil {
+			volume = *req.Volume
+		} else {
+			current, err := audio.GetVolume(r.Context())
+			if err != nil {
+				writeMixerError(w, r, err, "volume get failed", logger)
+				return
+			}
+			volume = current + delta
+		}
+		volume = max(0, min(100, volume))
+
+		if err := audio.SetVolume(r.Context(), volume); err != nil {
+			writeMixerError(w, r, err, "volume set failed", logger)
+			return
+		}
+		logger.Info("volume set",
 			"volume", volume,
+			"delta", delta,
 			"remote_addr", r.RemoteAddr,
 		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "volume set failed",
-			Message: err.Error(),
-		})
-		return
 	}
 
-	h.logger.Info("volume set",
-		"volume", volume,
-		"remote_addr", r.RemoteAddr,
-	)
+	if req.Mute != nil {
+		if err := audio.SetMute(r.Context(), *req.Mute); err != nil {
+			writeMixerError(w, r, err, "mute failed", logger)
+			return
+		}
+		logger.Info("mute set", "muted", *req.Mute, "remote_addr", r.RemoteAddr)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(VolumeResponse{
-		Status:    "ok",
-		Volume:    volume,
-		Timestamp: time.Now().Format(time.RFC3339),
-	})
+	writeVolumeState(w, r, logger)
+}
+
+func (h *VolumeMuteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	if err := audio.SetMute(r.Context(), h.muted); err != nil {
+		writeMixerError(w, r, err, "mute failed", logger)
+		return
+	}
+	logger.Info("mute set", "muted", h.muted, "remote_addr", r.RemoteAddr)
+
+	writeVolumeState(w, r, logger)
 }
 
 type VolumeGetHandler struct {
@@ -90,18 +156,13 @@ func NewVolumeGetHandler(logger *slog.Logger) *VolumeGetHandler {
 }
 
 func (h *VolumeGetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	volume, err := audio.GetVolume()
+	writeVolumeState(w, r, logging.FromContext(r.Context(), h.logger))
+}
+
+func writeVolumeState(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	volume, muted, err := audio.GetVolumeState(r.Context())
 	if err != nil {
-		h.logger.Error("volume get failed",
-			"error", err,
-			"remote_addr", r.RemoteAddr,
-		)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error:   "volume get failed",
-			Message: err.Error(),
-		})
+		writeMixerError(w, r, err, "volume get failed", logger)
 		return
 	}
 
@@ -110,6 +171,7 @@ func (h *VolumeGetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(VolumeResponse{
 		Status:    "ok",
 		Volume:    volume,
+		Muted:     muted,
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
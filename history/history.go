@@ -0,0 +1,214 @@
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	OutcomeCompleted   = "completed"
+	OutcomeFailed      = "failed"
+	OutcomeStarted     = "started"
+	OutcomeRejected    = "rejected"
+	OutcomeRateLimited = "rate_limited"
+)
+
+type Entry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Device     string    `json:"device,omitempty"`
+	Command    string    `json:"command,omitempty"`
+	TextHash   string    `json:"text_hash,omitempty"`
+	Engine     string    `json:"engine,omitempty"`
+	Voice      string    `json:"voice,omitempty"`
+	Volume     *int      `json:"volume,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+type Query struct {
+	Device string
+	Since  time.Time
+	Limit  int
+}
+
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	maxSize int64
+	logger  *slog.Logger
+}
+
+func Open(path string, maxSize int64, logger *slog.Logger) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, size, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("play history enabled", "path", path, "max_size", maxSize)
+	return &Store{
+		path:    path,
+		file:    file,
+		size:    size,
+		maxSize: maxSize,
+		logger:  logger,
+	}, nil
+}
+
+func openLog(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open history file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat history file: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+func (s *Store) rotatedPath() string {
+	return s.path + ".1"
+}
+
+func HashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *Store) Record(e Entry) {
+	if s == nil {
+		return
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		s.logger.Warn("failed to encode history entry", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		s.logger.Warn("failed to write history entry", "error", err, "path", s.path)
+	}
+	if s.maxSize > 0 && s.size >= s.maxSize {
+		s.rotateLocked()
+	}
+}
+
+func (s *Store) rotateLocked() {
+	s.file.Close()
+	s.file = nil
+	if err := os.Rename(s.path, s.rotatedPath()); err != nil {
+		s.logger.Warn("failed to rotate history file", "error", err, "path", s.path)
+	}
+	file, size, err := openLog(s.path)
+	if err != nil {
+		s.logger.Warn("failed to reopen history file", "error", err, "path", s.path)
+		return
+	}
+	s.file, s.size = file, size
+	s.logger.Info("history file rotated", "path", s.path, "rotated", s.rotatedPath())
+}
+
+func (s *Store) openForRead() ([]*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var files []*os.File
+	if file, err := os.Open(s.rotatedPath()); err == nil {
+		files = append(files, file)
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	return append(files, file), nil
+}
+
+func (s *Store) Query(q Query) ([]Entry, error) {
+	if s == nil {
+		return []Entry{}, nil
+	}
+
+	files, err := s.openForRead()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Entry
+	for _, file := range files {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var e Entry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				continue
+			}
+			if q.Device != "" && e.Device != q.Device {
+				continue
+			}
+			if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+				continue
+			}
+			matches = append(matches, e)
+			if q.Limit > 0 && len(matches) > q.Limit {
+				matches = matches[1:]
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read history file: %w", err)
+		}
+	}
+
+	entries := make([]Entry, 0, len(matches))
+	for i := len(matches) - 1; i >= 0; i-- {
+		entries = append(entries, matches[i])
+	}
+	return entries, nil
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, path string, maxSize int64) *Store {
+	t.Helper()
+	store, err := Open(path, maxSize, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func commands(entries []Entry) []string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Command
+	}
+	return names
+}
+
+func TestStoreRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := openTestStore(t, path, 300)
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, command := range []string{"a", "b", "c", "d", "e", "f"} {
+		store.Record(Entry{Timestamp: base.Add(time.Duration(i) * time.Minute), Device: "dreame", Command: command, Outcome: OutcomeCompleted})
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("rotated file missing: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("history file missing: %v", err)
+	}
+	if info.Size() >= 300 {
+		t.Errorf("history file size = %d, want below the 300 byte limit", info.Size())
+	}
+
+	entries, err := store.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if got := commands(entries); !slices.Equal(got, []string{"f", "e", "d"}) {
+		t.Errorf("Query() = %v, want [f e d], the entries before the second rotation dropped", got)
+	}
+}
+
+func TestStoreQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := openTestStore(t, path, 250)
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []Entry{
+		{Device: "dreame", Command: "a"},
+		{Device: "kitchen", Command: "b"},
+		{Device: "dreame", Command: "c"},
+		{Device: "dreame", Command: "d"},
+	}
+	for i, e := range records {
+		e.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		e.Outcome = OutcomeCompleted
+		store.Record(e)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("rotated file missing: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "all", query: Query{}, want: []string{"d", "c", "b", "a"}},
+		{name: "device", query: Query{Device: "dreame"}, want: []string{"d", "c", "a"}},
+		{name: "since", query: Query{Since: base.Add(2 * time.Minute)}, want: []string{"d", "c"}},
+		{name: "limit", query: Query{Limit: 2}, want: []string{"d", "c"}},
+		{name: "device and limit", query: Query{Device: "dreame", Limit: 1}, want: []string{"d"}},
+		{name: "unknown device", query: Query{Device: "garage"}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := store.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if got := commands(entries); !slices.Equal(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := openTestStore(t, path, 0)
+	store.Record(Entry{Command: "a", Outcome: OutcomeCompleted})
+	store.Close()
+
+	store = openTestStore(t, path, 0)
+	store.Record(Entry{Command: "b", Outcome: OutcomeCompleted})
+
+	entries, err := store.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if got := commands(entries); !slices.Equal(got, []string{"b", "a"}) {
+		t.Errorf("Query() = %v, want [b a]", got)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var store *Store
+	store.Record(Entry{Command: "a"})
+	entries, err := store.Query(Query{})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Query() = %v, %v, want no entries", entries, err)
+	}
+	if err := store.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
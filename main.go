@@ -14,6 +14,8 @@ import (
 	"jacadi/audio"
 	"jacadi/config"
 	"jacadi/handlers"
+	"jacadi/history"
+	"jacadi/tracing"
 	"jacadi/tts"
 )
 
@@ -28,6 +30,7 @@ func main() {
 		handler = slog.NewTextHandler(os.Stdout, opts)
 	}
 	logger := slog.New(handler)
+	slog.SetDefault(logger)
 
 	logger.Info("starting audio playback server", "commit", os.Getenv("GIT_COMMIT"))
 
@@ -85,6 +88,21 @@ func main() {
 
 	config.ApplyVolumeOverrides(deviceConfig, logger)
 
+	if err := deviceConfig.Validate(); err != nil {
+		logger.Error("invalid config", "error", err, "path", configPath)
+		os.Exit(1)
+	}
+
+	if calibrationJSON := config.GetVolumeCalibration(); calibrationJSON != "" {
+		calibrations, err := audio.ParseCalibrations(calibrationJSON)
+		if err != nil {
+			logger.Error("invalid VOLUME_CALIBRATION", "error", err)
+			os.Exit(1)
+		}
+		audio.SetCalibrations(calibrations)
+		logger.Info("loaded volume calibration", "sinks", len(calibrations))
+	}
+
 	logger.Info("configuration loaded",
 		"devices", len(deviceConfig),
 		"total_commands", deviceConfig.TotalCommands(),
@@ -93,34 +111,81 @@ func main() {
 		"port", port,
 	)
 
+	var exporter *tracing.Exporter
+	if url := config.GetOTLPTracesURL(); url != "" {
+		exporter = tracing.NewExporter(url, config.GetOTelServiceName(), config.GetEnv("GIT_COMMIT", "dev"), logger)
+		tracing.SetExporter(exporter)
+	}
+
 	aplayPlayer, err := audio.NewAplayPlayer(logger)
 	if err != nil {
 		logger.Error("failed to initialize audio player", "error", err)
 		os.Exit(1)
 	}
 
-	folderPlayer := audio.NewFolderPlayer(logger)
-	coordinator := audio.NewCoordinator(aplayPlayer, folderPlayer, logger)
+	folderPlayer := audio.NewFolderPlayer(config.GetMPVSocket(), logger)
+	coordinator := audio.NewCoordinator(aplayPlayer, folderPlayer, config.GetVolumeUnmuteOnPlay(), logger)
+
+	historyStore, err := history.Open(config.GetHistoryPath(), config.GetHistoryMaxSize(), logger)
+	if err != nil {
+		logger.Warn("play history disabled", "error", err, "path", config.GetHistoryPath())
+	}
+
+	limiter := handlers.NewRateLimiter(handlers.RateLimitConfig{
+		PerClient:  config.GetRateLimitClient(),
+		PerToken:   config.GetRateLimitToken(),
+		PerCommand: config.GetRateLimitCommand(),
+		Burst:      config.GetRateLimitBurst(),
+	}, historyStore, logger)
 
-	mux := http.NewServeMux()
+	router := handlers.NewRouter(config.GetEnv("GIT_COMMIT", "dev"), logger)
 
-	mux.HandleFunc("GET /health", healthCheckHandler(deviceConfig, logger))
+	router.Handle("GET /health", healthCheckHandler(deviceConfig, logger), handlers.Operation{
+		Summary: "Health check",
+		Tags:    []string{"system"},
+	})
+
+	engines, piperEngine := newTTSRegistry(logger)
+
+	var ttsCache *tts.Cache
+	if engines.Len() > 0 {
+		ttsCache, err = tts.NewCache(config.GetTTSCacheDir(), config.GetTTSCacheSize(), logger)
+		if err != nil {
+			logger.Warn("TTS cache disabled", "error", err, "dir", config.GetTTSCacheDir())
+		}
+	}
 
 	for deviceName, device := range deviceConfig {
 		for audioName, cmd := range device.Commands {
-			var path string
-			if cmd.Type == "folder" {
-				path = cmd.GetFolderPath(deviceName, audioName)
+			var handler http.Handler
+			if cmd.Type == "template" {
+				if ttsCache == nil {
+					logger.Warn("skipping template command, TTS is not available", "device", deviceName, "command", audioName)
+					continue
+				}
+				handler, err = handlers.NewTemplateHandler(coordinator, engines, ttsCache, deviceName, audioName, cmd, device, historyStore, logger)
+				if err != nil {
+					logger.Error("invalid template command", "error", err, "device", deviceName, "command", audioName)
+					os.Exit(1)
+				}
 			} else {
-				path = config.GetAudioFilePathForCommand(deviceName, audioName, cmd.IsExtra)
+				path := cmd.GetAudioPath(deviceName, audioName)
+				handler = handlers.NewPlaybackHandler(coordinator, deviceName, audioName, path, cmd, device.Volume, historyStore, logger)
 			}
-			handler := handlers.NewPlaybackHandler(coordinator, path, cmd.Type, device.Volume, logger)
 			pattern := fmt.Sprintf("POST /play/%s/%s", deviceName, audioName)
 
-			mux.Handle(pattern, handler)
-
-			logger.Info("registered route",
-				"pattern", pattern,
+			router.Handle(pattern, limiter.Wrap(deviceName, audioName, time.Duration(cmd.Cooldown), handler), handlers.Operation{
+				Summary:     fmt.Sprintf("Play %s on %s", audioName, deviceName),
+				Description: cmd.Text,
+				Tags:        []string{deviceName},
+				Responses: map[int]any{
+					http.StatusOK:                  handlers.PlaybackResponse{},
+					http.StatusBadRequest:          handlers.ErrorResponse{},
+					http.StatusNotFound:            handlers.ErrorResponse{},
+					http.StatusTooManyRequests:     handlers.ErrorResponse{},
+					http.StatusInternalServerError: handlers.ErrorResponse{},
+				},
+			},
 				"device", deviceName,
 				"audio_name", audioName,
 				"type", cmd.Type,
@@ -129,38 +194,419 @@ func main() {
 		}
 	}
 
+	composeHandler := handlers.NewComposeHandler(coordinator, deviceConfig, engines, ttsCache, config.GetComposeGap(), historyStore, logger)
+	router.Handle("POST /play/{device}/compose", limiter.WrapFunc(handlers.PathDevice, "compose", 0, composeHandler), handlers.Operation{
+		Summary:     "Play command fragments and TTS snippets as one stream",
+		Description: "Parts are concatenated in order with a silence of gap seconds between them (request or per part), converted to a common format and played as a single job.",
+		Tags:        []string{"playback"},
+		Request:     handlers.ComposeRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  handlers.ComposeResponse{},
+			http.StatusBadRequest:          handlers.ErrorResponse{},
+			http.StatusNotFound:            handlers.ErrorResponse{},
+			http.StatusTooManyRequests:     handlers.ErrorResponse{},
+			http.StatusInternalServerError: handlers.ErrorResponse{},
+		},
+	})
+
+	allowedHosts, err := handlers.ParseHostAllowList(config.GetPlayURLAllowedHosts())
+	if err != nil {
+		logger.Error("invalid PLAY_URL_ALLOWED_HOSTS", "error", err)
+		os.Exit(1)
+	}
+	maxClipSize := int64(config.GetPlayMaxSize()) << 20
+
+	playURLHandler := handlers.NewPlayURLHandler(coordinator, deviceConfig, allowedHosts, config.GetPlayURLTimeout(), maxClipSize, historyStore, logger)
+	router.Handle("POST /play/url", limiter.WrapFunc(handlers.BodyDevice, "url", 0, playURLHandler), handlers.Operation{
+		Summary:     "Fetch and play an audio clip",
+		Description: "The URL host must match PLAY_URL_ALLOWED_HOSTS. device only selects the volume.",
+		Tags:        []string{"playback"},
+		Request:     handlers.PlayURLRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                   handlers.ClipResponse{},
+			http.StatusBadRequest:           handlers.ErrorResponse{},
+			http.StatusForbidden:            handlers.ErrorResponse{},
+			http.StatusNotFound:             handlers.ErrorResponse{},
+			http.StatusUnsupportedMediaType: handlers.ErrorResponse{},
+			http.StatusTooManyRequests:      handlers.ErrorResponse{},
+			http.StatusBadGateway:           handlers.ErrorResponse{},
+		},
+	})
+
+	playRawHandler := handlers.NewPlayRawHandler(coordinator, deviceConfig, maxClipSize, historyStore, logger)
+	router.Handle("POST /play/raw", limiter.WrapFunc(handlers.QueryDevice, "raw", 0, playRawHandler), handlers.Operation{
+		Summary:     "Play the audio clip sent as request body",
+		Tags:        []string{"playback"},
+		Query:       []handlers.Parameter{{Name: "device", Description: "Device whose volume is used"}},
+		RequestType: "audio/wav",
+		Responses: map[int]any{
+			http.StatusOK:                   handlers.ClipResponse{},
+			http.StatusBadRequest:           handlers.ErrorResponse{},
+			http.StatusNotFound:             handlers.ErrorResponse{},
+			http.StatusUnsupportedMediaType: handlers.ErrorResponse{},
+			http.StatusTooManyRequests:      handlers.ErrorResponse{},
+		},
+	})
+
+	audioResponse := handlers.BinaryResponse{ContentTypes: []string{"audio/wav", "audio/ogg", "audio/mpeg"}}
+	formatParam := handlers.Parameter{Name: "format", Description: "wav (default), ogg or mp3, the latter two need ffmpeg"}
+
+	renderHandler := handlers.NewRenderHandler(deviceConfig, engines, ttsCache, logger)
+	router.Handle("GET /render/{device}/{command}", renderHandler, handlers.Operation{
+		Summary:     "Download the audio a command would play",
+		Description: "Template commands are rendered with the query parameters.",
+		Tags:        []string{"render"},
+		Query:       []handlers.Parameter{formatParam},
+		Responses: map[int]any{
+			http.StatusOK:                  audioResponse,
+			http.StatusBadRequest:          handlers.ErrorResponse{},
+			http.StatusNotFound:            handlers.ErrorResponse{},
+			http.StatusNotAcceptable:       handlers.ErrorResponse{},
+			http.StatusInternalServerError: handlers.ErrorResponse{},
+		},
+	})
+
+	devicesHandler := handlers.NewDevicesHandler(deviceConfig, logger)
+	router.Handle("GET /devices", devicesHandler, handlers.Operation{
+		Summary:   "List configured devices",
+		Tags:      []string{"devices"},
+		Responses: map[int]any{http.StatusOK: handlers.DevicesResponse{}},
+	})
+
+	deviceHandler := handlers.NewDeviceHandler(deviceConfig, ttsCache != nil, logger)
+	router.Handle("GET /devices/{device}", deviceHandler, handlers.Operation{
+		Summary: "Get a device and its commands",
+		Tags:    []string{"devices"},
+		Responses: map[int]any{
+			http.StatusOK:       handlers.DeviceInfo{},
+			http.StatusNotFound: handlers.ErrorResponse{},
+		},
+	})
+
+	deviceCommandsHandler := handlers.NewDeviceCommandsHandler(deviceConfig, ttsCache != nil, logger)
+	router.Handle("GET /devices/{device}/commands", deviceCommandsHandler, handlers.Operation{
+		Summary: "List the commands of a device",
+		Tags:    []string{"devices"},
+		Responses: map[int]any{
+			http.StatusOK:       handlers.CommandsResponse{},
+			http.StatusNotFound: handlers.ErrorResponse{},
+		},
+	})
+
 	stopHandler := handlers.NewStopHandler(coordinator, logger)
-	mux.Handle("POST /stop", stopHandler)
-	logger.Info("registered route", "pattern", "POST /stop")
+	router.Handle("POST /stop", stopHandler, handlers.Operation{
+		Summary:   "Stop every folder session",
+		Tags:      []string{"playback"},
+		Responses: map[int]any{http.StatusOK: handlers.PlaybackResponse{}},
+	})
+
+	folderStateHandler := handlers.NewFolderStateHandler(coordinator, logger)
+	router.Handle("GET /folder", folderStateHandler, handlers.Operation{
+		Summary:     "Get the playing folder",
+		Description: "Current track, position and duration in seconds, and playlist, read from mpv.",
+		Tags:        []string{"playback"},
+		Responses: map[int]any{
+			http.StatusOK:                  handlers.FolderResponse{},
+			http.StatusConflict:            handlers.ErrorResponse{},
+			http.StatusInternalServerError: handlers.ErrorResponse{},
+		},
+	})
+
+	folderSessionsHandler := handlers.NewFolderSessionsHandler(coordinator, logger)
+	router.Handle("GET /folders", folderSessionsHandler, handlers.Operation{
+		Summary:     "List folder sessions",
+		Description: "Sessions are ordered from the highest priority one, which is the one playing, to the lowest.",
+		Tags:        []string{"playback"},
+		Responses:   map[int]any{http.StatusOK: handlers.FolderSessionsResponse{}},
+	})
+
+	folderSessionHandler := handlers.NewFolderSessionHandler(coordinator, logger)
+	router.Handle("GET /folders/{id}", folderSessionHandler, handlers.Operation{
+		Summary: "Get a folder session",
+		Tags:    []string{"playback"},
+		Responses: map[int]any{
+			http.StatusOK:       audio.FolderSession{},
+			http.StatusNotFound: handlers.ErrorResponse{},
+		},
+	})
+
+	folderSessionStopHandler := handlers.NewFolderSessionStopHandler(coordinator, logger)
+	router.Handle("DELETE /folders/{id}", folderSessionStopHandler, handlers.Operation{
+		Summary:     "Stop a folder session",
+		Description: "The next session by priority resumes where it was preempted.",
+		Tags:        []string{"playback"},
+		Responses: map[int]any{
+			http.StatusOK:       handlers.PlaybackResponse{},
+			http.StatusNotFound: handlers.ErrorResponse{},
+		},
+	})
+
+	folderControls := []struct {
+		action  string
+		summary string
+	}{
+		{handlers.FolderActionNext, "Skip to the next folder track"},
+		{handlers.FolderActionPrev, "Go back to the previous folder track"},
+		{handlers.FolderActionPause, "Pause the folder"},
+		{handlers.FolderActionResume, "Resume the paused folder"},
+	}
+	for _, control := range folderControls {
+		router.Handle("POST /folder/"+control.action, handlers.NewFolderControlHandler(coordinator, control.action, logger), handlers.Operation{
+			Summary: control.summary,
+			Tags:    []string{"playback"},
+			Responses: map[int]any{
+				http.StatusOK:                  handlers.FolderResponse{},
+				http.StatusConflict:            handlers.ErrorResponse{},
+				http.StatusInternalServerError: handlers.ErrorResponse{},
+			},
+		})
+	}
 
-	volumeHandler := handlers.NewVolumeHandler(logger)
-	mux.Handle("POST /volume", volumeHandler)
-	logger.Info("registered route", "pattern", "POST /volume")
+	folderSeekHandler := handlers.NewFolderControlHandler(coordinator, handlers.FolderActionSeek, logger)
+	router.Handle("POST /folder/seek", folderSeekHandler, handlers.Operation{
+		Summary:     "Seek in the current folder track",
+		Description: "position is in seconds, from the start of the track or from the current position when relative is true.",
+		Tags:        []string{"playback"},
+		Request:     handlers.SeekRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  handlers.FolderResponse{},
+			http.StatusBadRequest:          handlers.ErrorResponse{},
+			http.StatusConflict:            handlers.ErrorResponse{},
+			http.StatusInternalServerError: handlers.ErrorResponse{},
+		},
+	})
+
+	volumeHandler := handlers.NewVolumeHandler(config.GetVolumeStep(), logger)
+	router.Handle("POST /volume", volumeHandler, handlers.Operation{
+		Summary:     "Set speaker volume",
+		Description: "Set one of volume (0-100), delta (relative percent) or step (up or down by VOLUME_STEP), and optionally mute.",
+		Tags:        []string{"volume"},
+		Request:     handlers.VolumeRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                 handlers.VolumeResponse{},
+			http.StatusBadRequest:         handlers.ErrorResponse{},
+			http.StatusServiceUnavailable: handlers.ErrorResponse{},
+		},
+	})
+
+	volumeSwitches := []struct {
+		action  string
+		muted   bool
+		summary string
+	}{
+		{"mute", true, "Mute the speaker"},
+		{"unmute", false, "Unmute the speaker"},
+	}
+	for _, volumeSwitch := range volumeSwitches {
+		router.Handle("POST /volume/"+volumeSwitch.action, handlers.NewVolumeMuteHandler(volumeSwitch.muted, logger), handlers.Operation{
+			Summary: volumeSwitch.summary,
+			Tags:    []string{"volume"},
+			Responses: map[int]any{
+				http.StatusOK:                 handlers.VolumeResponse{},
+				http.StatusBadRequest:         handlers.ErrorResponse{},
+				http.StatusServiceUnavailable: handlers.ErrorResponse{},
+			},
+		})
+	}
 
-	volumeGetHandler := handlers.NewVolumeGetHandler(logger)
-	mux.Handle("GET /volume", volumeGetHandler)
-	logger.Info("registered route", "pattern", "GET /volume")
+	calibrationHandler := handlers.NewCalibrationHandler(coordinator, logger)
+	router.Handle("POST /volume/calibrate", calibrationHandler, handlers.Operation{
+		Summary:     "Play a calibration tone at increasing volumes",
+		Description: "Plays a test tone once per step from quiet to full volume through the speaker calibration, then restores the original volume. min, max and curve override VOLUME_CALIBRATION for a trial run.",
+		Tags:        []string{"volume"},
+		Request:     handlers.CalibrationRequest{},
+		Responses: map[int]any{
+			http.StatusOK:         handlers.CalibrationResponse{},
+			http.StatusBadRequest: handlers.ErrorResponse{},
+		},
+	})
 
-	var speaker *tts.PiperSpeaker
-	if config.IsPiperEmbedded() {
-		var err error
-		speaker, err = tts.NewPiperSpeaker(logger)
-		if err != nil {
-			logger.Error("failed to initialize TTS speaker", "error", err)
-			os.Exit(1)
-		}
+	volumeGetHandler := handlers.NewVolumeGetHandler(logger)
+	router.Handle("GET /volume", volumeGetHandler, handlers.Operation{
+		Summary: "Get speaker volume and mute state",
+		Tags:    []string{"volume"},
+		Responses: map[int]any{
+			http.StatusOK:                 handlers.VolumeResponse{},
+			http.StatusServiceUnavailable: handlers.ErrorResponse{},
+		},
+	})
+
+	mixerHandler := handlers.NewMixerHandler(logger)
+	router.Handle("GET /mixer", mixerHandler, handlers.Operation{
+		Summary:     "List sound cards and mixer controls",
+		Description: "Every control with its capabilities, raw range, per-channel value, percent, dB and switch state.",
+		Tags:        []string{"volume"},
+		Responses: map[int]any{
+			http.StatusOK:                 handlers.MixerResponse{},
+			http.StatusServiceUnavailable: handlers.ErrorResponse{},
+		},
+	})
+
+	mixerControlHandler := handlers.NewMixerControlHandler(logger)
+	router.Handle("GET /mixer/{card}/{control}", mixerControlHandler, handlers.Operation{
+		Summary: "Get a mixer control",
+		Tags:    []string{"volume"},
+		Responses: map[int]any{
+			http.StatusOK:                 handlers.MixerControlResponse{},
+			http.StatusNotFound:           handlers.ErrorResponse{},
+			http.StatusServiceUnavailable: handlers.ErrorResponse{},
+		},
+	})
+
+	mixerSetHandler := handlers.NewMixerSetHandler(logger)
+	router.Handle("POST /mixer/{card}/{control}", mixerSetHandler, handlers.Operation{
+		Summary:     "Set a mixer control volume or balance",
+		Description: "Set either percent (0-100) or db, and optionally balance from -1 (left only) to 1 (right only) on stereo controls.",
+		Tags:        []string{"volume"},
+		Request:     handlers.MixerSetRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                 handlers.MixerControlResponse{},
+			http.StatusBadRequest:         handlers.ErrorResponse{},
+			http.StatusNotFound:           handlers.ErrorResponse{},
+			http.StatusServiceUnavailable: handlers.ErrorResponse{},
+		},
+	})
+
+	mixerSwitches := []struct {
+		action  string
+		muted   bool
+		summary string
+	}{
+		{"mute", true, "Mute a mixer control"},
+		{"unmute", false, "Unmute a mixer control"},
+	}
+	for _, mixerSwitch := range mixerSwitches {
+		router.Handle("POST /mixer/{card}/{control}/"+mixerSwitch.action, handlers.NewMixerMuteHandler(mixerSwitch.muted, logger), handlers.Operation{
+			Summary: mixerSwitch.summary,
+			Tags:    []string{"volume"},
+			Responses: map[int]any{
+				http.StatusOK:                 handlers.MixerControlResponse{},
+				http.StatusBadRequest:         handlers.ErrorResponse{},
+				http.StatusNotFound:           handlers.ErrorResponse{},
+				http.StatusServiceUnavailable: handlers.ErrorResponse{},
+			},
+		})
+	}
 
-		ttsHandler := handlers.NewTTSHandler(speaker, logger)
-		mux.Handle("POST /play/tts", ttsHandler)
-		logger.Info("registered TTS route", "pattern", "POST /play/tts")
+	historyHandler := handlers.NewHistoryHandler(historyStore, logger)
+	router.Handle("GET /history", historyHandler, handlers.Operation{
+		Summary: "Query play history",
+		Tags:    []string{"system"},
+		Query: []handlers.Parameter{
+			{Name: "device", Description: "Only return entries for this device"},
+			{Name: "since", Description: "RFC3339 timestamp or duration (e.g. 1h)"},
+			{Name: "limit", Description: "Maximum number of entries (default 100)"},
+		},
+		Responses: map[int]any{
+			http.StatusOK:                  handlers.HistoryResponse{},
+			http.StatusBadRequest:          handlers.ErrorResponse{},
+			http.StatusInternalServerError: handlers.ErrorResponse{},
+		},
+	})
+
+	var speaker *tts.EngineSpeaker
+	if engines.Len() > 0 {
+		speaker = tts.NewEngineSpeaker(engines, coordinator, logger)
+
+		ttsHandler := handlers.NewTTSHandler(speaker, engines, deviceConfig, historyStore, logger)
+		router.Handle("POST /play/tts", limiter.WrapFunc(handlers.BodyDevice, "tts", 0, ttsHandler), handlers.Operation{
+			Summary:     "Speak text with TTS",
+			Description: "Engine and voice default to the device's tts_engine/voice when device is set, then to the default engine.",
+			Tags:        []string{"tts"},
+			Request:     handlers.TTSRequest{},
+			Responses: map[int]any{
+				http.StatusOK:                  handlers.TTSResponse{},
+				http.StatusBadRequest:          handlers.ErrorResponse{},
+				http.StatusNotFound:            handlers.ErrorResponse{},
+				http.StatusTooManyRequests:     handlers.ErrorResponse{},
+				http.StatusInternalServerError: handlers.ErrorResponse{},
+			},
+		})
+
+		renderTTSHandler := handlers.NewRenderTTSHandler(engines, ttsCache, deviceConfig, logger)
+		router.Handle("POST /render/tts", renderTTSHandler, handlers.Operation{
+			Summary:     "Synthesize text and download the audio",
+			Description: "Accepts the same body as /play/tts and returns the audio instead of playing it.",
+			Tags:        []string{"render"},
+			Query:       []handlers.Parameter{formatParam},
+			Request:     handlers.TTSRequest{},
+			Responses: map[int]any{
+				http.StatusOK:                  audioResponse,
+				http.StatusBadRequest:          handlers.ErrorResponse{},
+				http.StatusNotFound:            handlers.ErrorResponse{},
+				http.StatusNotAcceptable:       handlers.ErrorResponse{},
+				http.StatusInternalServerError: handlers.ErrorResponse{},
+			},
+		})
 	} else {
-		logger.Info("TTS endpoint disabled (set PIPER_EMBEDDED=true to enable)")
+		logger.Info("TTS endpoint disabled (set PIPER_EMBEDDED=true, TTS_COMMAND or TTS_HTTP_URL, or install espeak-ng to enable)")
+	}
+
+	if piperEngine != nil {
+		voiceCatalog := piperEngine.Voices()
+		voicesHandler := handlers.NewVoicesHandler(voiceCatalog, logger)
+		router.Handle("GET /tts/voices", voicesHandler, handlers.Operation{
+			Summary: "List installed voice models",
+			Tags:    []string{"tts"},
+			Responses: map[int]any{
+				http.StatusOK:                  handlers.VoicesResponse{},
+				http.StatusInternalServerError: handlers.ErrorResponse{},
+			},
+		})
+
+		voiceInstallHandler := handlers.NewVoiceInstallHandler(voiceCatalog, logger)
+		router.Handle("POST /tts/voices/{name}", voiceInstallHandler, handlers.Operation{
+			Summary:     "Install a voice model",
+			Description: "Body is a .tar or .tar.gz containing {name}.onnx and {name}.onnx.json (raw or multipart 'file' field), or JSON {\"path\": ...} pointing to a local tarball.",
+			Tags:        []string{"tts"},
+			Query: []handlers.Parameter{
+				{Name: "overwrite", Description: "Replace an already installed voice"},
+			},
+			RequestType: "application/gzip",
+			Responses: map[int]any{
+				http.StatusCreated:    tts.Voice{},
+				http.StatusBadRequest: handlers.ErrorResponse{},
+				http.StatusConflict:   handlers.ErrorResponse{},
+			},
+		})
+
+		voiceDeleteHandler := handlers.NewVoiceDeleteHandler(voiceCatalog, logger)
+		router.Handle("DELETE /tts/voices/{name}", voiceDeleteHandler, handlers.Operation{
+			Summary:     "Delete a voice model",
+			Description: "Removes {name}.onnx and {name}.onnx.json and stops the piper worker serving it.",
+			Tags:        []string{"tts"},
+			Responses: map[int]any{
+				http.StatusNoContent:  nil,
+				http.StatusBadRequest: handlers.ErrorResponse{},
+			},
+		})
 	}
 
+	statusHandler := handlers.NewStatusHandler(coordinator, engines, logger)
+	router.Handle("GET /status", statusHandler, handlers.Operation{
+		Summary:   "Current playback status and volume",
+		Tags:      []string{"system"},
+		Responses: map[int]any{http.StatusOK: handlers.StatusResponse{}},
+	})
+
+	router.Handle("GET /{$}", handlers.UIHandler(), handlers.Operation{
+		Summary: "Web soundboard",
+		Tags:    []string{"system"},
+	})
+
+	router.Handle("GET /openapi.json", router.OpenAPIHandler(), handlers.Operation{
+		Summary: "OpenAPI specification of the registered routes",
+		Tags:    []string{"system"},
+	})
+	router.Handle("GET /docs", handlers.DocsHandler(), handlers.Operation{
+		Summary: "Interactive API documentation",
+		Tags:    []string{"system"},
+	})
+
 	addr := fmt.Sprintf("%s:%d", host, port)
 	srv := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: router,
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -184,13 +630,23 @@ func main() {
 		logger.Error("server shutdown error", "error", err)
 	}
 
+	if speaker != nil {
+		if err := speaker.Close(); err != nil {
+			logger.Error("error closing TTS speaker", "error", err)
+		}
+	}
+
 	if err := coordinator.Close(); err != nil {
 		logger.Error("error closing coordinator", "error", err)
 	}
 
-	if speaker != nil {
-		if err := speaker.Close(); err != nil {
-			logger.Error("error closing TTS speaker", "error", err)
+	if err := historyStore.Close(); err != nil {
+		logger.Error("error closing play history", "error", err)
+	}
+
+	if exporter != nil {
+		if err := exporter.Close(); err != nil {
+			logger.Error("error closing trace exporter", "error", err)
 		}
 	}
 
@@ -218,3 +674,38 @@ func healthCheckHandler(deviceConfig config.DeviceConfig, logger *slog.Logger) h
 		json.NewEncoder(w).Encode(response)
 	}
 }
+
+func newTTSRegistry(logger *slog.Logger) (*tts.Registry, *tts.PiperEngine) {
+	engines := tts.NewRegistry(config.GetTTSEngine(), logger)
+
+	var piperEngine *tts.PiperEngine
+	if config.IsPiperEmbedded() {
+		voiceCatalog := tts.NewVoiceCatalog(config.GetVoicesDir(), logger)
+		engine, err := tts.NewPiperEngine(voiceCatalog, config.GetDefaultVoice(), config.GetPiperMaxWorkers(), config.GetPiperWorkerIdleTimeout(), config.GetPiperSynthesisTimeout(), logger)
+		if err != nil {
+			logger.Error("failed to initialize piper engine", "error", err)
+			os.Exit(1)
+		}
+		engines.Register(engine)
+		piperEngine = engine
+	}
+
+	if engine, err := tts.NewEspeakEngine(config.GetEspeakVoice()); err == nil {
+		engines.Register(engine)
+	}
+
+	if command := config.GetTTSCommand(); command != "" {
+		engine, err := tts.NewCommandEngine(command, config.GetTTSCommandVoice())
+		if err != nil {
+			logger.Warn("command TTS engine disabled", "error", err, "command", command)
+		} else {
+			engines.Register(engine)
+		}
+	}
+
+	if url := config.GetTTSHTTPURL(); url != "" {
+		engines.Register(tts.NewHTTPEngine(url, config.GetTTSHTTPModel(), config.GetTTSHTTPAPIKey(), config.GetTTSHTTPVoice(), config.GetTTSHTTPTimeout()))
+	}
+
+	return engines, piperEngine
+}
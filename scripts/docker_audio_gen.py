@@ -23,7 +23,7 @@ commands = []
 for device_name, device_config in devices.items():
     os.makedirs(f"{OUT}/{device_name}", exist_ok=True)
     for audio_name, command_info in device_config["commands"].items():
-        if command_info.get("type") == "folder":
+        if command_info.get("type") in ("folder", "template") or command_info.get("file"):
             continue
         commands.append({
             "device": device_name,
@@ -0,0 +1,240 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	exportBatchSize = 256
+	exportInterval  = 2 * time.Second
+	exportQueueSize = 2048
+	exportTimeout   = 5 * time.Second
+)
+
+type Exporter struct {
+	url     string
+	service string
+	version string
+	client  *http.Client
+	logger  *slog.Logger
+	spans   chan otlpSpan
+	done    chan struct{}
+	wg      sync.WaitGroup
+	closed  atomic.Bool
+	dropped atomic.Int64
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func NewExporter(url, service, version string, logger *slog.Logger) *Exporter {
+	e := &Exporter{
+		url:     url,
+		service: service,
+		version: version,
+		client:  &http.Client{Timeout: exportTimeout},
+		logger:  logger,
+		spans:   make(chan otlpSpan, exportQueueSize),
+		done:    make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+
+	logger.Info("OTLP trace export enabled", "url", url, "service", service)
+	return e
+}
+
+func (e *Exporter) export(s *Span, end time.Time) {
+	if e.closed.Load() {
+		return
+	}
+
+	s.mu.Lock()
+	span := otlpSpan{
+		TraceID:           s.sc.TraceID,
+		SpanID:            s.sc.SpanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.status, Message: s.message},
+	}
+	for _, attr := range s.attrs {
+		span.Attributes = append(span.Attributes, otlpAttr(attr.key, attr.value))
+	}
+	s.mu.Unlock()
+
+	select {
+	case e.spans <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *Exporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []otlpSpan
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				e.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.flush(batch)
+				batch = nil
+			}
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					if len(batch) > 0 {
+						e.flush(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) flush(batch []otlpSpan) {
+	if dropped := e.dropped.Swap(0); dropped > 0 {
+		e.logger.Warn("trace export queue full, spans dropped", "dropped", dropped)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			otlpAttr("service.name", e.service),
+			otlpAttr("service.version", e.version),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "jacadi", Version: e.version},
+			Spans: batch,
+		}},
+	}}})
+	if err != nil {
+		e.logger.Warn("failed to encode spans", "error", err)
+		return
+	}
+
+	if err := e.post(body); err != nil {
+		e.logger.Warn("trace export failed", "error", err, "spans", len(batch), "url", e.url)
+	}
+}
+
+func (e *Exporter) post(body []byte) error {
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *Exporter) Close() error {
+	if e.closed.Swap(true) {
+		return nil
+	}
+	close(e.done)
+	e.wg.Wait()
+	return nil
+}
+
+func otlpAttr(key string, value any) otlpAttribute {
+	var v otlpValue
+	switch val := value.(type) {
+	case string:
+		v.StringValue = &val
+	case bool:
+		v.BoolValue = &val
+	case int:
+		s := strconv.Itoa(val)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		v.IntValue = &s
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			s := strconv.FormatFloat(val, 'g', -1, 64)
+			v.StringValue = &s
+		} else {
+			v.DoubleValue = &val
+		}
+	default:
+		s := fmt.Sprint(val)
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
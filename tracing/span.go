@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+const statusError = 2
+
+var defaultExporter atomic.Pointer[Exporter]
+
+func SetExporter(e *Exporter) {
+	defaultExporter.Store(e)
+}
+
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
+}
+
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID + "-" + sc.SpanID + "-" + flags
+}
+
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	if !isHexID(parts[1], 32) || !isHexID(parts[2], 16) || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	return SpanContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags[0]&1 == 1}, true
+}
+
+func isHexID(s string, n int) bool {
+	if len(s) != n || strings.Trim(s, "0") == "" || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type attribute struct {
+	key   string
+	value any
+}
+
+type Span struct {
+	exporter *Exporter
+	sc       SpanContext
+	parentID string
+	kind     int
+	start    time.Time
+
+	mu      sync.Mutex
+	name    string
+	attrs   []attribute
+	status  int
+	message string
+	ended   bool
+}
+
+type spanKey struct{}
+
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+func Start(ctx context.Context, name string, attrs ...any) (context.Context, *Span) {
+	var parent SpanContext
+	if span := FromContext(ctx); span != nil {
+		parent = span.sc
+	}
+	return start(ctx, name, KindInternal, parent, attrs)
+}
+
+func StartClient(ctx context.Context, name string, attrs ...any) (context.Context, *Span) {
+	var parent SpanContext
+	if span := FromContext(ctx); span != nil {
+		parent = span.sc
+	}
+	return start(ctx, name, KindClient, parent, attrs)
+}
+
+func StartServer(ctx context.Context, name string, remote SpanContext, attrs ...any) (context.Context, *Span) {
+	return start(ctx, name, KindServer, remote, attrs)
+}
+
+func start(ctx context.Context, name string, kind int, parent SpanContext, attrs []any) (context.Context, *Span) {
+	exporter := defaultExporter.Load()
+	span := &Span{
+		exporter: exporter,
+		kind:     kind,
+		start:    time.Now(),
+		name:     name,
+	}
+	if parent.Valid() {
+		span.sc = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.parentID = parent.SpanID
+	} else {
+		span.sc = SpanContext{TraceID: randomID(16), Sampled: exporter != nil}
+	}
+	span.sc.SpanID = randomID(8)
+	span.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *Span) Context() SpanContext {
+	return s.sc
+}
+
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetAttributes(attrs ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(attrs); i += 2 {
+		key, ok := attrs[i].(string)
+		if !ok {
+			key = fmt.Sprint(attrs[i])
+		}
+		s.attrs = append(s.attrs, attribute{key: key, value: attrs[i+1]})
+	}
+}
+
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = statusError
+	s.message = err.Error()
+}
+
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.mu.Unlock()
+
+	if s.exporter == nil || !s.sc.Sampled {
+		return
+	}
+	s.exporter.export(s, time.Now())
+}
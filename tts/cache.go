@@ -0,0 +1,146 @@
+package tts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"jacadi/audio"
+)
+
+type Cache struct {
+	mu         sync.Mutex
+	dir        string
+	maxEntries int
+	inflight   map[string]*renderCall
+	logger     *slog.Logger
+}
+
+type renderCall struct {
+	done chan struct{}
+	wav  []byte
+	err  error
+}
+
+func NewCache(dir string, maxEntries int, logger *slog.Logger) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create TTS cache directory: %w", err)
+	}
+	logger.Info("TTS cache enabled", "dir", dir, "max_entries", maxEntries)
+	return &Cache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		inflight:   make(map[string]*renderCall),
+		logger:     logger,
+	}, nil
+}
+
+func (c *Cache) key(engine Engine, req Request) string {
+	data, _ := json.Marshal(struct {
+		Engine       string
+		VoiceVersion string
+		Request
+	}{engine.Name(), voiceVersion(engine, req.Voice), req})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+func (c *Cache) Render(ctx context.Context, engine Engine, req Request) ([]byte, bool, error) {
+	if req.Voice == "" {
+		req.Voice = engine.DefaultVoice()
+	}
+	req.Engine = ""
+	key := c.key(engine, req)
+	path := filepath.Join(c.dir, key+".wav")
+
+	if wav, err := os.ReadFile(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return wav, true, nil
+	}
+
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.wav, call.err == nil, call.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	call := &renderCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.wav, call.err = c.render(ctx, engine, req, path)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.pruneLocked()
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.wav, false, call.err
+}
+
+func (c *Cache) render(ctx context.Context, engine Engine, req Request, path string) ([]byte, error) {
+	result, err := Synthesize(ctx, engine, req)
+	if err != nil {
+		return nil, err
+	}
+	wav := audio.EncodeWav(result.PCM, result.SampleRate, result.Channels)
+
+	tmp, err := os.CreateTemp(c.dir, ".render-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	if _, err := tmp.Write(wav); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to store cache file: %w", err)
+	}
+	return wav, nil
+}
+
+func (c *Cache) pruneLocked() {
+	if c.maxEntries <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(filepath.Join(c.dir, "*.wav"))
+	if err != nil || len(matches) <= c.maxEntries {
+		return
+	}
+
+	modTimes := make(map[string]time.Time, len(matches))
+	for _, path := range matches {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return modTimes[matches[i]].Before(modTimes[matches[j]]) })
+
+	for _, path := range matches[:len(matches)-c.maxEntries] {
+		if err := os.Remove(path); err != nil {
+			c.logger.Warn("failed to evict TTS cache entry", "error", err, "path", path)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeEngine struct {
+	calls   atomic.Int32
+	release chan struct{}
+	version atomic.Value
+}
+
+func (e *fakeEngine) Name() string                     { return "fake" }
+func (e *fakeEngine) DefaultVoice() string             { return "bob" }
+func (e *fakeEngine) ValidateVoice(voice string) error { return nil }
+func (e *fakeEngine) Close() error                     { return nil }
+
+func (e *fakeEngine) VoiceVersion(voice string) string {
+	version, _ := e.version.Load().(string)
+	return version
+}
+
+func (e *fakeEngine) Synthesize(text, voice string, prosody Prosody) (*Audio, error) {
+	e.calls.Add(1)
+	if e.release != nil {
+		<-e.release
+	}
+	return &Audio{PCM: make([]byte, 320), SampleRate: 16000, Channels: 1}, nil
+}
+
+func newTestCache(t *testing.T, maxEntries int) *Cache {
+	t.Helper()
+	cache, err := NewCache(t.TempDir(), maxEntries, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	return cache
+}
+
+func TestCacheRenderDeduplicates(t *testing.T) {
+	cache := newTestCache(t, 0)
+	engine := &fakeEngine{release: make(chan struct{})}
+	req := Request{Text: "Hello"}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := cache.Render(context.Background(), engine, req); err != nil {
+				t.Errorf("Render() error = %v", err)
+			}
+		}()
+	}
+	for engine.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(engine.release)
+	wg.Wait()
+
+	if got := engine.calls.Load(); got != 1 {
+		t.Errorf("engine called %d times, want 1", got)
+	}
+	if _, hit, err := cache.Render(context.Background(), engine, req); err != nil || !hit {
+		t.Errorf("Render() hit = %v, error = %v, want a cache hit", hit, err)
+	}
+}
+
+func TestCacheRenderVoiceVersion(t *testing.T) {
+	cache := newTestCache(t, 0)
+	engine := &fakeEngine{}
+	engine.version.Store("1")
+	req := Request{Text: "Hello", Voice: "bob"}
+
+	tests := []struct {
+		name    string
+		version string
+		wantHit bool
+	}{
+		{name: "first render", version: "1", wantHit: false},
+		{name: "same model", version: "1", wantHit: true},
+		{name: "model replaced", version: "2", wantHit: false},
+		{name: "replaced model cached", version: "2", wantHit: true},
+	}
+
+	for _, tt := range tests {
+		engine.version.Store(tt.version)
+		_, hit, err := cache.Render(context.Background(), engine, req)
+		if err != nil {
+			t.Fatalf("%s: Render() error = %v", tt.name, err)
+		}
+		if hit != tt.wantHit {
+			t.Errorf("%s: Render() hit = %v, want %v", tt.name, hit, tt.wantHit)
+		}
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	cache := newTestCache(t, 2)
+	engine := &fakeEngine{}
+
+	for _, text := range []string{"one", "two", "three"} {
+		if _, _, err := cache.Render(context.Background(), engine, Request{Text: text}); err != nil {
+			t.Fatalf("Render(%q) error = %v", text, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, hit, _ := cache.Render(context.Background(), engine, Request{Text: "one"}); hit {
+		t.Errorf("Render(%q) hit the cache, want the oldest entry evicted", "one")
+	}
+	if _, hit, _ := cache.Render(context.Background(), engine, Request{Text: "three"}); !hit {
+		t.Errorf("Render(%q) missed the cache, want a hit", "three")
+	}
+}
@@ -0,0 +1,67 @@
+package tts
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+type CommandEngine struct {
+	args         []string
+	defaultVoice string
+}
+
+func NewCommandEngine(command, defaultVoice string) (*CommandEngine, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty TTS command")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("TTS command not found: %w", err)
+	}
+	return &CommandEngine{
+		args:         args,
+		defaultVoice: defaultVoice,
+	}, nil
+}
+
+func (e *CommandEngine) Name() string {
+	return "command"
+}
+
+func (e *CommandEngine) DefaultVoice() string {
+	return e.defaultVoice
+}
+
+func (e *CommandEngine) ValidateVoice(voice string) error {
+	return nil
+}
+
+func (e *CommandEngine) Synthesize(text, voice string, prosody Prosody) (*Audio, error) {
+	replacer := strings.NewReplacer(
+		"{voice}", voice,
+		"{speed}", strconv.FormatFloat(prosody.speed(), 'f', -1, 64),
+	)
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("TTS command failed: %w, stderr: %s", err, stderr.String())
+	}
+
+	return decodeWavAudio(stdout.Bytes())
+}
+
+func (e *CommandEngine) Close() error {
+	return nil
+}
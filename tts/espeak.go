@@ -0,0 +1,112 @@
+package tts
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"jacadi/audio"
+)
+
+const (
+	espeakWordsPerMinute = 175
+	espeakPitch          = 50
+)
+
+type EspeakEngine struct {
+	binary       string
+	defaultVoice string
+
+	voicesOnce sync.Once
+	voices     map[string]bool
+}
+
+func NewEspeakEngine(defaultVoice string) (*EspeakEngine, error) {
+	binary, err := exec.LookPath("espeak-ng")
+	if err != nil {
+		return nil, fmt.Errorf("espeak-ng not found: %w", err)
+	}
+	return &EspeakEngine{
+		binary:       binary,
+		defaultVoice: defaultVoice,
+	}, nil
+}
+
+func (e *EspeakEngine) Name() string {
+	return "espeak-ng"
+}
+
+func (e *EspeakEngine) DefaultVoice() string {
+	return e.defaultVoice
+}
+
+func (e *EspeakEngine) ValidateVoice(voice string) error {
+	e.voicesOnce.Do(e.loadVoices)
+	name, _, _ := strings.Cut(voice, "+")
+	if len(e.voices) == 0 || e.voices[name] {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownVoice, voice)
+}
+
+func (e *EspeakEngine) loadVoices() {
+	output, err := exec.Command(e.binary, "--voices").Output()
+	if err != nil {
+		return
+	}
+	e.voices = make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		e.voices[fields[1]] = true
+		e.voices[fields[3]] = true
+		e.voices[fields[4]] = true
+		if _, name, ok := strings.Cut(fields[4], "/"); ok {
+			e.voices[name] = true
+		}
+	}
+}
+
+func (e *EspeakEngine) SupportsPitch() bool {
+	return true
+}
+
+func (e *EspeakEngine) Synthesize(text, voice string, prosody Prosody) (*Audio, error) {
+	args := []string{"--stdout", "--stdin", "-v", voice}
+	if prosody.Speed > 0 {
+		args = append(args, "-s", strconv.Itoa(int(math.Round(espeakWordsPerMinute*prosody.Speed))))
+	}
+	if prosody.Pitch > 0 {
+		args = append(args, "-p", strconv.Itoa(min(99, int(math.Round(espeakPitch*prosody.Pitch)))))
+	}
+
+	cmd := exec.Command(e.binary, args...)
+	cmd.Stdin = strings.NewReader(text)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("espeak-ng failed: %w, stderr: %s", err, stderr.String())
+	}
+
+	return decodeWavAudio(stdout.Bytes())
+}
+
+func (e *EspeakEngine) Close() error {
+	return nil
+}
+
+func decodeWavAudio(data []byte) (*Audio, error) {
+	info, pcm, err := audio.DecodeWav(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode engine output: %w", err)
+	}
+	return &Audio{PCM: pcm, SampleRate: info.SampleRate, Channels: info.Channels}, nil
+}
@@ -0,0 +1,93 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const maxHTTPAudioSize = 64 << 20
+
+type HTTPEngine struct {
+	url          string
+	model        string
+	apiKey       string
+	defaultVoice string
+	client       *http.Client
+}
+
+type speechRequest struct {
+	Model          string  `json:"model,omitempty"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice,omitempty"`
+	ResponseFormat string  `json:"response_format"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+func NewHTTPEngine(url, model, apiKey, defaultVoice string, timeout time.Duration) *HTTPEngine {
+	return &HTTPEngine{
+		url:          url,
+		model:        model,
+		apiKey:       apiKey,
+		defaultVoice: defaultVoice,
+		client:       &http.Client{Timeout: timeout},
+	}
+}
+
+func (e *HTTPEngine) Name() string {
+	return "http"
+}
+
+func (e *HTTPEngine) DefaultVoice() string {
+	return e.defaultVoice
+}
+
+func (e *HTTPEngine) ValidateVoice(voice string) error {
+	return nil
+}
+
+func (e *HTTPEngine) Synthesize(text, voice string, prosody Prosody) (*Audio, error) {
+	body, err := json.Marshal(speechRequest{
+		Model:          e.model,
+		Input:          text,
+		Voice:          voice,
+		ResponseFormat: "wav",
+		Speed:          prosody.Speed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode speech request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "audio/wav")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("TTS server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPAudioSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TTS server response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TTS server returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	return decodeWavAudio(data)
+}
+
+func (e *HTTPEngine) Close() error {
+	return nil
+}
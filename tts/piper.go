@@ -0,0 +1,87 @@
+package tts
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"time"
+)
+
+type PiperEngine struct {
+	voices       *VoiceCatalog
+	workers      *WorkerPool
+	defaultVoice string
+}
+
+func NewPiperEngine(voices *VoiceCatalog, defaultVoice string, maxWorkers int, idleTimeout, synthTimeout time.Duration, logger *slog.Logger) (*PiperEngine, error) {
+	if _, err := exec.LookPath("python"); err != nil {
+		return nil, fmt.Errorf("python not found: %w", err)
+	}
+
+	logger.Info("piper engine initialized",
+		"voices_dir", voices.Dir(),
+		"max_workers", maxWorkers,
+		"worker_idle_timeout", idleTimeout,
+		"synthesis_timeout", synthTimeout,
+	)
+
+	workers := NewWorkerPool(maxWorkers, idleTimeout, synthTimeout, logger)
+	voices.OnChange(workers.Evict)
+	return &PiperEngine{
+		voices:       voices,
+		workers:      workers,
+		defaultVoice: defaultVoice,
+	}, nil
+}
+
+func (e *PiperEngine) Name() string {
+	return "piper"
+}
+
+func (e *PiperEngine) DefaultVoice() string {
+	return e.defaultVoice
+}
+
+func (e *PiperEngine) Voices() *VoiceCatalog {
+	return e.voices
+}
+
+func (e *PiperEngine) ValidateVoice(voice string) error {
+	_, err := e.voices.Lookup(voice)
+	return err
+}
+
+func (e *PiperEngine) VoiceVersion(voice string) string {
+	v, err := e.voices.Lookup(voice)
+	if err != nil {
+		return ""
+	}
+	return v.version()
+}
+
+func (e *PiperEngine) Synthesize(text, voice string, prosody Prosody) (*Audio, error) {
+	v, err := e.voices.Lookup(voice)
+	if err != nil {
+		return nil, err
+	}
+
+	req := synthesisRequest{
+		Text:       text,
+		NoiseScale: prosody.Noise,
+		NoiseW:     prosody.NoiseW,
+	}
+	if prosody.Speed > 0 {
+		req.LengthScale = 1 / prosody.Speed
+	}
+
+	pcm, sampleRate, err := e.workers.Synthesize(v, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Audio{PCM: pcm, SampleRate: sampleRate, Channels: 1}, nil
+}
+
+func (e *PiperEngine) Close() error {
+	e.workers.Close()
+	return nil
+}
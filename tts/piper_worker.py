@@ -0,0 +1,36 @@
+import json
+import os
+import sys
+
+# Keep stdout for the protocol: anything printed by piper goes to stderr.
+out = os.fdopen(os.dup(1), "wb")
+os.dup2(2, 1)
+
+from piper import PiperVoice, SynthesisConfig
+
+
+def send(header, payload=b""):
+    out.write((json.dumps(header) + "\n").encode())
+    out.write(payload)
+    out.flush()
+
+
+voice = PiperVoice.load(sys.argv[1])
+sample_rate = voice.config.sample_rate
+send({"ready": True, "sample_rate": sample_rate})
+
+for line in sys.stdin.buffer:
+    if not line.strip():
+        continue
+    try:
+        req = json.loads(line)
+        syn_config = SynthesisConfig(
+            length_scale=req.get("length_scale"),
+            noise_scale=req.get("noise_scale"),
+            noise_w_scale=req.get("noise_w"),
+        )
+        chunks = voice.synthesize(req["text"], syn_config=syn_config)
+        audio = b"".join(chunk.audio_int16_bytes for chunk in chunks)
+        send({"bytes": len(audio), "sample_rate": sample_rate}, audio)
+    except Exception as e:
+        send({"error": str(e)})
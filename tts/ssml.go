@@ -0,0 +1,339 @@
+package tts
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"jacadi/tracing"
+)
+
+const (
+	maxBreak   = 10 * time.Second
+	MaxSilence = 60 * time.Second
+)
+
+var (
+	ErrInvalidSSML    = errors.New("invalid SSML")
+	ErrTooMuchSilence = errors.New("too much silence")
+
+	sentenceEndRe = regexp.MustCompile(`[.!?;:]+\s+`)
+
+	breakStrengths = map[string]time.Duration{
+		"none":     0,
+		"x-weak":   100 * time.Millisecond,
+		"weak":     250 * time.Millisecond,
+		"medium":   500 * time.Millisecond,
+		"strong":   750 * time.Millisecond,
+		"x-strong": time.Second,
+	}
+
+	prosodyRates = map[string]float64{
+		"x-slow":  0.5,
+		"slow":    0.75,
+		"medium":  1,
+		"default": 1,
+		"fast":    1.25,
+		"x-fast":  1.5,
+	}
+)
+
+type Segment struct {
+	Text  string
+	Rate  float64
+	Pause time.Duration
+}
+
+func IsSSML(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "<speak")
+}
+
+func ParseSSML(text string) ([]Segment, error) {
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	decoder.Entity = xml.HTMLEntity
+
+	var segments []Segment
+	var current strings.Builder
+	var pauses time.Duration
+	rates := []float64{1}
+	var sayAs []string
+
+	flush := func() {
+		if words := strings.Fields(current.String()); len(words) > 0 {
+			segments = append(segments, Segment{Text: strings.Join(words, " "), Rate: rates[len(rates)-1]})
+		}
+		current.Reset()
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSSML, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "break":
+				pause, err := parseBreak(t)
+				if err != nil {
+					return nil, err
+				}
+				if pauses += pause; pauses > MaxSilence {
+					return nil, fmt.Errorf("%w: breaks add up to more than %s", ErrInvalidSSML, MaxSilence)
+				}
+				flush()
+				segments = append(segments, Segment{Pause: pause})
+			case "prosody":
+				rate, err := parseRate(attr(t, "rate"))
+				if err != nil {
+					return nil, err
+				}
+				flush()
+				rates = append(rates, rates[len(rates)-1]*rate)
+			case "say-as":
+				sayAs = append(sayAs, attr(t, "interpret-as"))
+			case "s", "p":
+				current.WriteByte(' ')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "prosody":
+				flush()
+				rates = rates[:len(rates)-1]
+			case "say-as":
+				sayAs = sayAs[:len(sayAs)-1]
+			case "s", "p":
+				current.WriteByte(' ')
+			}
+		case xml.CharData:
+			data := string(t)
+			if len(sayAs) > 0 {
+				data = interpretAs(sayAs[len(sayAs)-1], data)
+			}
+			current.WriteString(data)
+		}
+	}
+	flush()
+
+	return segments, nil
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func parseBreak(el xml.StartElement) (time.Duration, error) {
+	if value := attr(el, "time"); value != "" {
+		pause, err := time.ParseDuration(value)
+		if err != nil || pause < 0 {
+			return 0, fmt.Errorf("%w: invalid break time %q", ErrInvalidSSML, value)
+		}
+		return min(pause, maxBreak), nil
+	}
+
+	strength := attr(el, "strength")
+	if strength == "" {
+		strength = "medium"
+	}
+	pause, ok := breakStrengths[strength]
+	if !ok {
+		return 0, fmt.Errorf("%w: invalid break strength %q", ErrInvalidSSML, strength)
+	}
+	return pause, nil
+}
+
+func parseRate(value string) (float64, error) {
+	if value == "" {
+		return 1, nil
+	}
+	if rate, ok := prosodyRates[value]; ok {
+		return rate, nil
+	}
+
+	number, relative := strings.CutSuffix(value, "%")
+	rate, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid prosody rate %q", ErrInvalidSSML, value)
+	}
+	if relative {
+		if strings.HasPrefix(number, "+") || strings.HasPrefix(number, "-") {
+			rate += 100
+		}
+		rate /= 100
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("%w: invalid prosody rate %q", ErrInvalidSSML, value)
+	}
+	return rate, nil
+}
+
+func interpretAs(kind, text string) string {
+	var parts []string
+	switch kind {
+	case "characters", "spell-out", "verbatim":
+		for _, r := range text {
+			if !unicode.IsSpace(r) {
+				parts = append(parts, string(r))
+			}
+		}
+	case "digits", "telephone":
+		for _, r := range text {
+			switch {
+			case unicode.IsDigit(r):
+				parts = append(parts, string(r))
+			case !unicode.IsSpace(r) && len(parts) > 0 && parts[len(parts)-1] != ",":
+				parts = append(parts, ",")
+			}
+		}
+	default:
+		return text
+	}
+	return " " + strings.ReplaceAll(strings.Join(parts, " "), " ,", ",") + " "
+}
+
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEndRe.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, strings.TrimSpace(text[start:loc[1]]))
+		start = loc[1]
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+func segmentsOf(text string) ([]Segment, error) {
+	if IsSSML(text) {
+		return ParseSSML(text)
+	}
+	return []Segment{{Text: text, Rate: 1}}, nil
+}
+
+func CheckSilence(text string, sentenceSilence time.Duration) error {
+	segments, err := segmentsOf(text)
+	if err != nil {
+		return err
+	}
+	return checkSilence(segments, sentenceSilence)
+}
+
+func checkSilence(segments []Segment, sentenceSilence time.Duration) error {
+	var total time.Duration
+	gaps := -1
+	for _, segment := range segments {
+		total += segment.Pause
+		if segment.Text != "" {
+			gaps += len(splitSentences(segment.Text))
+		}
+	}
+	if sentenceSilence > 0 && gaps > 0 {
+		total += time.Duration(gaps) * sentenceSilence
+	}
+	if total > MaxSilence {
+		return fmt.Errorf("%w: %s of breaks and sentence silence, at most %s", ErrTooMuchSilence, total.Round(time.Millisecond), MaxSilence)
+	}
+	return nil
+}
+
+func Synthesize(ctx context.Context, engine Engine, req Request) (*Audio, error) {
+	_, span := tracing.Start(ctx, "tts synthesize",
+		"tts.engine", engine.Name(),
+		"tts.voice", req.Voice,
+		"text_length", len(req.Text),
+	)
+	defer span.End()
+
+	result, err := synthesize(engine, req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttributes("bytes", len(result.PCM))
+	return result, nil
+}
+
+func synthesize(engine Engine, req Request) (*Audio, error) {
+	segments, err := segmentsOf(req.Text)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSilence(segments, req.SentenceSilence); err != nil {
+		return nil, err
+	}
+
+	var result *Audio
+	var pending time.Duration
+	appendSilence := func(d time.Duration) {
+		if result == nil {
+			pending += d
+			return
+		}
+		result.PCM = append(result.PCM, Silence(result.SampleRate, result.Channels, d)...)
+	}
+
+	for i, segment := range segments {
+		if segment.Text == "" {
+			appendSilence(segment.Pause)
+			continue
+		}
+
+		sentences := []string{segment.Text}
+		if req.SentenceSilence > 0 {
+			sentences = splitSentences(segment.Text)
+		}
+
+		prosody := req.Prosody
+		prosody.Speed = prosody.speed() * segment.Rate
+		if prosody.Speed == 1 && req.Prosody.Speed == 0 {
+			prosody.Speed = 0
+		}
+
+		for j, sentence := range sentences {
+			audio, err := engine.Synthesize(sentence, req.Voice, prosody)
+			if err != nil {
+				return nil, err
+			}
+
+			if result == nil {
+				result = &Audio{SampleRate: audio.SampleRate, Channels: audio.Channels}
+				appendSilence(pending)
+			} else if audio.SampleRate != result.SampleRate || audio.Channels != result.Channels {
+				return nil, fmt.Errorf("engine returned mismatched audio formats (%d Hz/%d ch, then %d Hz/%d ch)",
+					result.SampleRate, result.Channels, audio.SampleRate, audio.Channels)
+			}
+			result.PCM = append(result.PCM, audio.PCM...)
+
+			if req.SentenceSilence > 0 && (j < len(sentences)-1 || i < len(segments)-1) {
+				appendSilence(req.SentenceSilence)
+			}
+		}
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("nothing to synthesize")
+	}
+	return result, nil
+}
+
+func Silence(sampleRate, channels int, d time.Duration) []byte {
+	frames := int(d.Seconds() * float64(sampleRate))
+	return make([]byte, frames*channels*2)
+}
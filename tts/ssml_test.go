@@ -0,0 +1,118 @@
+package tts
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSSML(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []Segment
+		wantErr error
+	}{
+		{
+			name: "plain text",
+			text: "<speak>Hello world</speak>",
+			want: []Segment{{Text: "Hello world", Rate: 1}},
+		},
+		{
+			name: "break time",
+			text: `<speak>Okay dream <break time="700ms"/> clean</speak>`,
+			want: []Segment{{Text: "Okay dream", Rate: 1}, {Pause: 700 * time.Millisecond}, {Text: "clean", Rate: 1}},
+		},
+		{
+			name: "break strength",
+			text: `<speak><break strength="strong"/>Go</speak>`,
+			want: []Segment{{Pause: breakStrengths["strong"]}, {Text: "Go", Rate: 1}},
+		},
+		{
+			name: "break capped",
+			text: `<speak><break time="30s"/>Go</speak>`,
+			want: []Segment{{Pause: maxBreak}, {Text: "Go", Rate: 1}},
+		},
+		{
+			name: "nested prosody",
+			text: `<speak>a <prosody rate="slow">b <prosody rate="50%">c</prosody> d</prosody> e</speak>`,
+			want: []Segment{
+				{Text: "a", Rate: 1},
+				{Text: "b", Rate: prosodyRates["slow"]},
+				{Text: "c", Rate: prosodyRates["slow"] * 0.5},
+				{Text: "d", Rate: prosodyRates["slow"]},
+				{Text: "e", Rate: 1},
+			},
+		},
+		{
+			name: "say-as inside prosody",
+			text: `<speak><prosody rate="fast">call <say-as interpret-as="digits">42</say-as></prosody></speak>`,
+			want: []Segment{{Text: "call 4 2", Rate: prosodyRates["fast"]}},
+		},
+		{name: "invalid break time", text: `<speak><break time="soon"/></speak>`, wantErr: ErrInvalidSSML},
+		{name: "negative break time", text: `<speak><break time="-1s"/></speak>`, wantErr: ErrInvalidSSML},
+		{name: "invalid break strength", text: `<speak><break strength="loud"/></speak>`, wantErr: ErrInvalidSSML},
+		{name: "invalid rate", text: `<speak><prosody rate="warp">a</prosody></speak>`, wantErr: ErrInvalidSSML},
+		{name: "unclosed tag", text: `<speak><prosody rate="slow">a</speak>`, wantErr: ErrInvalidSSML},
+		{
+			name:    "breaks above the total cap",
+			text:    "<speak>" + strings.Repeat(`<break time="10s"/>`, 7) + "</speak>",
+			wantErr: ErrInvalidSSML,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSSML(tt.text)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseSSML() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSSML() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSSML() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSilence(t *testing.T) {
+	sentences := strings.Repeat("Go now. ", 13)
+
+	tests := []struct {
+		name            string
+		text            string
+		sentenceSilence time.Duration
+		wantErr         error
+	}{
+		{name: "plain text", text: sentences},
+		{name: "sentence silence within the cap", text: sentences, sentenceSilence: 5 * time.Second},
+		{name: "sentence silence above the cap", text: sentences, sentenceSilence: 6 * time.Second, wantErr: ErrTooMuchSilence},
+		{name: "single sentence", text: "Go now.", sentenceSilence: 10 * time.Second},
+		{
+			name:            "breaks and sentence silence",
+			text:            `<speak>One. Two. <break time="10s"/><break time="10s"/><break time="10s"/><break time="10s"/><break time="10s"/> Three.</speak>`,
+			sentenceSilence: 6 * time.Second,
+			wantErr:         ErrTooMuchSilence,
+		},
+		{name: "invalid ssml", text: `<speak><break time="soon"/></speak>`, wantErr: ErrInvalidSSML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSilence(tt.text, tt.sentenceSilence)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("CheckSilence() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckSilence() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,145 +1,264 @@
 package tts
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
-	"os"
-	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
-	"jacadi/config"
+	"jacadi/audio"
+	"jacadi/logging"
 )
 
+var ErrUnknownEngine = errors.New("unknown TTS engine")
+
 type Speaker interface {
-	SpeakAsync(text, voice string) error
+	SpeakAsync(ctx context.Context, req Request, volume *int, done func(error)) error
+	Close() error
+}
+
+type Request struct {
+	Text            string
+	Voice           string
+	Engine          string
+	Prosody         Prosody
+	SentenceSilence time.Duration
+}
+
+type Prosody struct {
+	Speed  float64
+	Pitch  float64
+	Noise  float64
+	NoiseW float64
+}
+
+func (p Prosody) speed() float64 {
+	if p.Speed <= 0 {
+		return 1
+	}
+	return p.Speed
+}
+
+type Audio struct {
+	PCM        []byte
+	SampleRate int
+	Channels   int
+}
+
+type Engine interface {
+	Name() string
+	DefaultVoice() string
+	ValidateVoice(voice string) error
+	Synthesize(text, voice string, prosody Prosody) (*Audio, error)
 	Close() error
 }
 
-type PiperSpeaker struct {
-	wg         sync.WaitGroup
-	logger     *slog.Logger
-	closing    atomic.Bool
-	sampleRate int
-	audiodev   string
+type pitchEngine interface {
+	SupportsPitch() bool
+}
+
+func SupportsPitch(engine Engine) bool {
+	e, ok := engine.(pitchEngine)
+	return ok && e.SupportsPitch()
+}
+
+type versionedEngine interface {
+	VoiceVersion(voice string) string
+}
+
+func voiceVersion(engine Engine, voice string) string {
+	if e, ok := engine.(versionedEngine); ok {
+		return e.VoiceVersion(voice)
+	}
+	return ""
+}
+
+type Registry struct {
+	mu            sync.RWMutex
+	engines       map[string]Engine
+	defaultEngine string
+	logger        *slog.Logger
+}
+
+func NewRegistry(defaultEngine string, logger *slog.Logger) *Registry {
+	return &Registry{
+		engines:       make(map[string]Engine),
+		defaultEngine: defaultEngine,
+		logger:        logger,
+	}
+}
+
+func (r *Registry) Register(engine Engine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.engines[engine.Name()] = engine
+	r.logger.Info("registered TTS engine", "engine", engine.Name(), "default_voice", engine.DefaultVoice())
+}
+
+func (r *Registry) Get(name string) (Engine, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		name = r.defaultLocked()
+	}
+	engine, ok := r.engines[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEngine, name)
+	}
+	return engine, nil
+}
+
+func (r *Registry) Default() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultLocked()
+}
+
+func (r *Registry) defaultLocked() string {
+	if _, ok := r.engines[r.defaultEngine]; ok {
+		return r.defaultEngine
+	}
+	names := r.namesLocked()
+	if len(names) == 0 {
+		return r.defaultEngine
+	}
+	return names[0]
+}
+
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.namesLocked()
 }
 
-func NewPiperSpeaker(logger *slog.Logger) (*PiperSpeaker, error) {
-	if _, err := exec.LookPath("python"); err != nil {
-		return nil, fmt.Errorf("python not found: %w", err)
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.engines))
+	for name := range r.engines {
+		names = append(names, name)
 	}
-	if _, err := exec.LookPath("aplay"); err != nil {
-		return nil, fmt.Errorf("aplay not found: %w", err)
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.engines)
+}
+
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for _, engine := range r.engines {
+		if err := engine.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", engine.Name(), err))
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	sampleRate := config.GetPiperSampleRate()
-	audiodev := os.Getenv("AUDIODEV")
+type EngineSpeaker struct {
+	wg          sync.WaitGroup
+	logger      *slog.Logger
+	closing     atomic.Bool
+	engines     *Registry
+	coordinator *audio.Coordinator
+}
 
-	logger.Info("piper TTS speaker initialized",
-		"sample_rate", sampleRate,
-		"audiodev", audiodev,
+func NewEngineSpeaker(engines *Registry, coordinator *audio.Coordinator, logger *slog.Logger) *EngineSpeaker {
+	logger.Info("TTS speaker initialized",
+		"engines", engines.Names(),
+		"default_engine", engines.Default(),
 	)
 
-	return &PiperSpeaker{
-		logger:     logger,
-		sampleRate: sampleRate,
-		audiodev:   audiodev,
-	}, nil
+	return &EngineSpeaker{
+		logger:      logger,
+		engines:     engines,
+		coordinator: coordinator,
+	}
 }
 
-func (s *PiperSpeaker) SpeakAsync(text, voice string) error {
+func (s *EngineSpeaker) SpeakAsync(ctx context.Context, req Request, volume *int, done func(error)) error {
 	if s.closing.Load() {
 		return fmt.Errorf("speaker is closing")
 	}
 
-	if strings.TrimSpace(text) == "" {
+	if strings.TrimSpace(req.Text) == "" {
 		return fmt.Errorf("text cannot be empty")
 	}
 
-	if voice == "" {
-		voice = config.GetDefaultVoice()
+	engine, err := s.engines.Get(req.Engine)
+	if err != nil {
+		return err
+	}
+	if req.Voice == "" {
+		req.Voice = engine.DefaultVoice()
 	}
 
+	ctx = context.WithoutCancel(ctx)
+	logger := logging.FromContext(ctx, s.logger)
 	s.wg.Add(1)
 
 	go func() {
 		defer s.wg.Done()
 
-		s.logger.Info("TTS started", "voice", voice, "text_length", len(text))
+		logger.Info("TTS started", "engine", engine.Name(), "voice", req.Voice, "text_length", len(req.Text))
 
-		if err := s.speak(text, voice); err != nil {
-			s.logger.Error("TTS failed",
-				"voice", voice,
+		err := s.speak(ctx, engine, req, volume)
+		if done != nil {
+			done(err)
+		}
+		if err != nil {
+			logger.Error("TTS failed",
+				"engine", engine.Name(),
+				"voice", req.Voice,
 				"error", err,
 			)
 			return
 		}
 
-		s.logger.Info("TTS completed", "voice", voice)
+		logger.Info("TTS completed", "engine", engine.Name(), "voice", req.Voice)
 	}()
 
 	return nil
 }
 
-func (s *PiperSpeaker) speak(text, voice string) error {
-	piperCmd := exec.Command("python", "-m", "piper", "--model", voice, "--output-raw", "--data-dir", os.Getenv("VOICES_DIR"))
-	piperCmd.Stdin = strings.NewReader(text)
-
-	aplayArgs := []string{
-		"-r", fmt.Sprintf("%d", s.sampleRate),
-		"-f", "S16_LE",
-		"-t", "raw",
-		"-q",
-	}
-	if s.audiodev != "" {
-		aplayArgs = append(aplayArgs, "-D", s.audiodev)
-	}
-	aplayArgs = append(aplayArgs, "-")
-
-	aplayCmd := exec.Command("aplay", aplayArgs...)
-
-	piperStdout, err := piperCmd.StdoutPipe()
+func (s *EngineSpeaker) speak(ctx context.Context, engine Engine, req Request, volume *int) error {
+	logger := logging.FromContext(ctx, s.logger)
+	speech, err := Synthesize(ctx, engine, req)
 	if err != nil {
-		return fmt.Errorf("failed to create piper stdout pipe: %w", err)
-	}
-	aplayCmd.Stdin = piperStdout
-
-	var piperStderr, aplayStderr strings.Builder
-	piperCmd.Stderr = &piperStderr
-	aplayCmd.Stderr = &aplayStderr
-
-	if err := piperCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start piper: %w", err)
-	}
-	if err := aplayCmd.Start(); err != nil {
-		piperCmd.Process.Kill()
-		return fmt.Errorf("failed to start aplay: %w", err)
-	}
-
-	go func() {
-		piperCmd.Wait()
-		piperStdout.(io.Closer).Close()
-	}()
-
-	if err := aplayCmd.Wait(); err != nil {
-		return fmt.Errorf("aplay failed: %w, stderr: %s", err, aplayStderr.String())
+		return err
 	}
 
-	if err := piperCmd.Wait(); err != nil {
-		if !strings.Contains(err.Error(), "already finished") {
-			return fmt.Errorf("piper failed: %w, stderr: %s", err, piperStderr.String())
-		}
-	}
+	logger.Info("TTS synthesized",
+		"engine", engine.Name(),
+		"voice", req.Voice,
+		"sample_rate", speech.SampleRate,
+		"channels", speech.Channels,
+		"bytes", len(speech.PCM),
+	)
 
-	return nil
+	return s.coordinator.PlayPCM(ctx, audio.PCM{
+		Data:       speech.PCM,
+		SampleRate: speech.SampleRate,
+		Channels:   speech.Channels,
+	}, volume)
 }
 
-func (s *PiperSpeaker) Close() error {
+func (s *EngineSpeaker) Close() error {
 	s.closing.Store(true)
 	s.logger.Info("closing TTS speaker, waiting for active speech to finish...")
 	s.wg.Wait()
+	err := s.engines.Close()
 	s.logger.Info("TTS speaker closed")
-	return nil
+	return err
 }
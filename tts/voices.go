@@ -0,0 +1,343 @@
+package tts
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownVoice = errors.New("unknown voice")
+	ErrVoiceExists  = errors.New("voice already installed")
+
+	voiceNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+type Voice struct {
+	Name         string `json:"name"`
+	Language     string `json:"language,omitempty"`
+	LanguageName string `json:"language_name,omitempty"`
+	Quality      string `json:"quality,omitempty"`
+	SampleRate   int    `json:"sample_rate,omitempty"`
+	NumSpeakers  int    `json:"num_speakers,omitempty"`
+	ModelPath    string `json:"-"`
+	ConfigPath   string `json:"-"`
+}
+
+func (v Voice) version() string {
+	var parts []string
+	for _, path := range []string{v.ModelPath, v.ConfigPath} {
+		if info, err := os.Stat(path); err == nil {
+			parts = append(parts, fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+type voiceConfig struct {
+	Audio struct {
+		SampleRate int    `json:"sample_rate"`
+		Quality    string `json:"quality"`
+	} `json:"audio"`
+	Language struct {
+		Code        string `json:"code"`
+		NameEnglish string `json:"name_english"`
+	} `json:"language"`
+	NumSpeakers int `json:"num_speakers"`
+}
+
+type VoiceCatalog struct {
+	mu       sync.Mutex
+	dir      string
+	voices   []Voice
+	loaded   bool
+	onChange func(name string)
+	logger   *slog.Logger
+}
+
+func NewVoiceCatalog(dir string, logger *slog.Logger) *VoiceCatalog {
+	return &VoiceCatalog{
+		dir:    dir,
+		logger: logger,
+	}
+}
+
+func (c *VoiceCatalog) Dir() string {
+	return c.dir
+}
+
+func (c *VoiceCatalog) OnChange(fn func(name string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = fn
+}
+
+func (c *VoiceCatalog) List() ([]Voice, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded {
+		if err := c.scanLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return append([]Voice(nil), c.voices...), nil
+}
+
+func (c *VoiceCatalog) scanLocked() error {
+	var voices []Voice
+	if _, err := os.Stat(c.dir); os.IsNotExist(err) {
+		c.voices, c.loaded = nil, true
+		return nil
+	}
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != c.dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".onnx") {
+			return nil
+		}
+		voice, err := loadVoice(path)
+		if err != nil {
+			c.logger.Warn("skipping voice model", "path", path, "error", err)
+			return nil
+		}
+		voices = append(voices, voice)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan voices directory: %w", err)
+	}
+
+	sort.Slice(voices, func(i, j int) bool { return voices[i].Name < voices[j].Name })
+	c.voices, c.loaded = voices, true
+	return nil
+}
+
+func (c *VoiceCatalog) findLocked(name string) (Voice, bool) {
+	for _, v := range c.voices {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Voice{}, false
+}
+
+func (c *VoiceCatalog) changedLocked(name string) {
+	c.loaded = false
+	if c.onChange != nil {
+		c.onChange(name)
+	}
+}
+
+func (c *VoiceCatalog) Lookup(name string) (Voice, error) {
+	if !voiceNameRe.MatchString(name) {
+		return Voice{}, fmt.Errorf("%w: %s", ErrUnknownVoice, name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.loaded {
+		if v, ok := c.findLocked(name); ok {
+			return v, nil
+		}
+	}
+	if err := c.scanLocked(); err != nil {
+		return Voice{}, err
+	}
+	if v, ok := c.findLocked(name); ok {
+		return v, nil
+	}
+	return Voice{}, fmt.Errorf("%w: %s", ErrUnknownVoice, name)
+}
+
+func (c *VoiceCatalog) InstallFile(name, path string, overwrite bool) (Voice, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Voice{}, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	return c.Install(name, f, overwrite)
+}
+
+func (c *VoiceCatalog) Install(name string, archive io.Reader, overwrite bool) (Voice, error) {
+	if !voiceNameRe.MatchString(name) {
+		return Voice{}, fmt.Errorf("invalid voice name %q", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modelPath := filepath.Join(c.dir, name+".onnx")
+	configPath := modelPath + ".json"
+	if _, err := os.Stat(modelPath); err == nil && !overwrite {
+		return Voice{}, fmt.Errorf("%w: %s", ErrVoiceExists, name)
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return Voice{}, fmt.Errorf("failed to create voices directory: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(c.dir, ".install-")
+	if err != nil {
+		return Voice{}, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpModel := filepath.Join(tmpDir, name+".onnx")
+	tmpConfig := tmpModel + ".json"
+	if err := extractVoice(archive, name, tmpModel, tmpConfig); err != nil {
+		return Voice{}, err
+	}
+
+	if _, err := loadVoice(tmpModel); err != nil {
+		return Voice{}, err
+	}
+
+	if err := os.Rename(tmpConfig, configPath); err != nil {
+		return Voice{}, fmt.Errorf("failed to install voice config: %w", err)
+	}
+	if err := os.Rename(tmpModel, modelPath); err != nil {
+		return Voice{}, fmt.Errorf("failed to install voice model: %w", err)
+	}
+
+	c.changedLocked(name)
+	voice, err := loadVoice(modelPath)
+	if err != nil {
+		return Voice{}, err
+	}
+	c.logger.Info("voice installed", "voice", name, "path", modelPath)
+	return voice, nil
+}
+
+func (c *VoiceCatalog) Delete(name string) error {
+	if !voiceNameRe.MatchString(name) {
+		return fmt.Errorf("%w: %s", ErrUnknownVoice, name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded {
+		if err := c.scanLocked(); err != nil {
+			return err
+		}
+	}
+	voice, ok := c.findLocked(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownVoice, name)
+	}
+
+	if err := os.Remove(voice.ModelPath); err != nil {
+		return fmt.Errorf("failed to remove voice model: %w", err)
+	}
+	if err := os.Remove(voice.ConfigPath); err != nil && !os.IsNotExist(err) {
+		c.logger.Warn("failed to remove voice config", "error", err, "path", voice.ConfigPath)
+	}
+	c.changedLocked(name)
+	c.logger.Info("voice deleted", "voice", name, "path", voice.ModelPath)
+	return nil
+}
+
+func extractVoice(archive io.Reader, name, modelPath, configPath string) error {
+	br := bufio.NewReader(archive)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	foundModel, foundConfig := false, false
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		var dest string
+		switch filepath.Base(hdr.Name) {
+		case name + ".onnx":
+			dest = modelPath
+			foundModel = true
+		case name + ".onnx.json":
+			dest = configPath
+			foundConfig = true
+		default:
+			continue
+		}
+
+		out, err := os.Create(dest)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Base(dest), err)
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to extract %s: %w", filepath.Base(dest), err)
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(dest), err)
+		}
+	}
+
+	if !foundModel || !foundConfig {
+		return fmt.Errorf("archive must contain %s.onnx and %s.onnx.json", name, name)
+	}
+	return nil
+}
+
+func loadVoice(modelPath string) (Voice, error) {
+	configPath := modelPath + ".json"
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return Voice{}, fmt.Errorf("failed to read voice config: %w", err)
+	}
+
+	var cfg voiceConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Voice{}, fmt.Errorf("failed to parse voice config: %w", err)
+	}
+	if cfg.Audio.SampleRate <= 0 {
+		return Voice{}, fmt.Errorf("voice config has no sample rate")
+	}
+
+	return Voice{
+		Name:         strings.TrimSuffix(filepath.Base(modelPath), ".onnx"),
+		Language:     cfg.Language.Code,
+		LanguageName: cfg.Language.NameEnglish,
+		Quality:      cfg.Audio.Quality,
+		SampleRate:   cfg.Audio.SampleRate,
+		NumSpeakers:  cfg.NumSpeakers,
+		ModelPath:    modelPath,
+		ConfigPath:   configPath,
+	}, nil
+}
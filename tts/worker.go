@@ -0,0 +1,433 @@
+package tts
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+//go:embed piper_worker.py
+var piperWorkerScript string
+
+const (
+	workerStartTimeout = 2 * time.Minute
+	workerStderrSize   = 8192
+)
+
+var (
+	errWorkerExited  = errors.New("piper worker exited")
+	errWorkerTimeout = errors.New("piper worker timed out")
+)
+
+type workerHeader struct {
+	Ready      bool   `json:"ready"`
+	Bytes      int    `json:"bytes"`
+	SampleRate int    `json:"sample_rate"`
+	Error      string `json:"error"`
+}
+
+type synthesisRequest struct {
+	Text        string  `json:"text"`
+	LengthScale float64 `json:"length_scale,omitempty"`
+	NoiseScale  float64 `json:"noise_scale,omitempty"`
+	NoiseW      float64 `json:"noise_w,omitempty"`
+}
+
+type piperWorker struct {
+	mu         sync.Mutex
+	voice      Voice
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stdout     *bufio.Reader
+	stderr     *tailWriter
+	sampleRate int
+	lastUsed   time.Time
+	exited     chan struct{}
+	logger     *slog.Logger
+}
+
+func startWorker(voice Voice, logger *slog.Logger) (*piperWorker, error) {
+	cmd := exec.Command("python", "-u", "-c", piperWorkerScript, voice.ModelPath)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worker stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worker stdout pipe: %w", err)
+	}
+	stderr := &tailWriter{size: workerStderrSize}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start piper worker: %w", err)
+	}
+
+	w := &piperWorker{
+		voice:    voice,
+		cmd:      cmd,
+		stdin:    stdin,
+		stdout:   bufio.NewReader(stdout),
+		stderr:   stderr,
+		lastUsed: time.Now(),
+		exited:   make(chan struct{}),
+		logger:   logger,
+	}
+
+	go func() {
+		err := cmd.Wait()
+		close(w.exited)
+		logger.Info("piper worker exited", "voice", voice.Name, "pid", cmd.Process.Pid, "error", err)
+	}()
+
+	ready := make(chan error, 1)
+	go func() {
+		header, err := w.readHeader()
+		if err == nil && !header.Ready {
+			err = fmt.Errorf("unexpected worker handshake")
+		}
+		if err == nil {
+			w.sampleRate = header.SampleRate
+		}
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			w.stop()
+			return nil, fmt.Errorf("piper worker failed to load %s: %w, stderr: %s", voice.Name, err, stderr.String())
+		}
+	case <-time.After(workerStartTimeout):
+		w.stop()
+		return nil, fmt.Errorf("piper worker for %s did not become ready in %s", voice.Name, workerStartTimeout)
+	}
+
+	logger.Info("piper worker started",
+		"voice", voice.Name,
+		"pid", cmd.Process.Pid,
+		"sample_rate", w.sampleRate,
+	)
+	return w, nil
+}
+
+func (w *piperWorker) synthesize(req synthesisRequest, timeout time.Duration) (pcm []byte, sampleRate int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.alive() {
+		return nil, 0, errWorkerExited
+	}
+	w.lastUsed = time.Now()
+
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			w.logger.Warn("piper worker timed out, killing it", "voice", w.voice.Name, "timeout", timeout)
+			w.cmd.Process.Kill()
+		})
+		defer func() {
+			if !timer.Stop() {
+				err = fmt.Errorf("%w after %s", errWorkerTimeout, timeout)
+			}
+		}()
+	}
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode synthesis request: %w", err)
+	}
+	if _, err := w.stdin.Write(append(line, '\n')); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errWorkerExited, err)
+	}
+
+	header, err := w.readHeader()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v, stderr: %s", errWorkerExited, err, w.stderr.String())
+	}
+	if header.Error != "" {
+		return nil, 0, fmt.Errorf("piper synthesis failed: %s", header.Error)
+	}
+
+	pcm = make([]byte, header.Bytes)
+	if _, err := io.ReadFull(w.stdout, pcm); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errWorkerExited, err)
+	}
+	w.lastUsed = time.Now()
+	return pcm, header.SampleRate, nil
+}
+
+func (w *piperWorker) readHeader() (workerHeader, error) {
+	line, err := w.stdout.ReadBytes('\n')
+	if err != nil {
+		return workerHeader{}, err
+	}
+	var header workerHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return workerHeader{}, fmt.Errorf("invalid worker header: %w", err)
+	}
+	return header, nil
+}
+
+func (w *piperWorker) alive() bool {
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+func (w *piperWorker) idleSince() time.Time {
+	if !w.mu.TryLock() {
+		return time.Now()
+	}
+	defer w.mu.Unlock()
+	return w.lastUsed
+}
+
+func (w *piperWorker) stop() {
+	w.stdin.Close()
+	select {
+	case <-w.exited:
+	case <-time.After(5 * time.Second):
+		w.cmd.Process.Kill()
+		<-w.exited
+	}
+}
+
+type WorkerPool struct {
+	mu           sync.Mutex
+	workers      map[string]*piperWorker
+	starting     map[string]*workerStart
+	maxWorkers   int
+	idleTimeout  time.Duration
+	synthTimeout time.Duration
+	closing      bool
+	done         chan struct{}
+	logger       *slog.Logger
+}
+
+type workerStart struct {
+	modelPath string
+	done      chan struct{}
+	worker    *piperWorker
+	err       error
+}
+
+func NewWorkerPool(maxWorkers int, idleTimeout, synthTimeout time.Duration, logger *slog.Logger) *WorkerPool {
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	p := &WorkerPool{
+		workers:      make(map[string]*piperWorker),
+		starting:     make(map[string]*workerStart),
+		maxWorkers:   maxWorkers,
+		idleTimeout:  idleTimeout,
+		synthTimeout: synthTimeout,
+		done:         make(chan struct{}),
+		logger:       logger,
+	}
+	if idleTimeout > 0 {
+		go p.reapIdle()
+	}
+	return p
+}
+
+func (p *WorkerPool) Synthesize(voice Voice, req synthesisRequest) ([]byte, int, error) {
+	for attempt := 0; ; attempt++ {
+		w, err := p.get(voice)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		pcm, sampleRate, err := w.synthesize(req, p.synthTimeout)
+		if errors.Is(err, errWorkerTimeout) {
+			p.remove(voice.Name, w)
+			return nil, 0, err
+		}
+		if err == nil || !errors.Is(err, errWorkerExited) || attempt > 0 {
+			return pcm, sampleRate, err
+		}
+
+		p.logger.Warn("piper worker crashed, restarting", "voice", voice.Name, "error", err)
+		p.remove(voice.Name, w)
+	}
+}
+
+func (p *WorkerPool) get(voice Voice) (*piperWorker, error) {
+	p.mu.Lock()
+	if p.closing {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("worker pool is closing")
+	}
+
+	if w, ok := p.workers[voice.Name]; ok {
+		if w.alive() && w.voice.ModelPath == voice.ModelPath {
+			p.mu.Unlock()
+			return w, nil
+		}
+		delete(p.workers, voice.Name)
+		go w.stop()
+	}
+
+	if start, ok := p.starting[voice.Name]; ok && start.modelPath == voice.ModelPath {
+		p.mu.Unlock()
+		<-start.done
+		return start.worker, start.err
+	}
+
+	start := &workerStart{modelPath: voice.ModelPath, done: make(chan struct{})}
+	p.starting[voice.Name] = start
+	for len(p.workers) > 0 && len(p.workers)+len(p.starting) > p.maxWorkers {
+		p.evictLocked()
+	}
+	p.mu.Unlock()
+
+	w, err := startWorker(voice, p.logger)
+
+	p.mu.Lock()
+	current := p.starting[voice.Name] == start
+	if current {
+		delete(p.starting, voice.Name)
+	}
+	switch {
+	case err != nil:
+	case p.closing:
+		go w.stop()
+		w, err = nil, fmt.Errorf("worker pool is closing")
+	case !current:
+		go w.stop()
+		p.mu.Unlock()
+		w, err = p.get(voice)
+		start.worker, start.err = w, err
+		close(start.done)
+		return w, err
+	default:
+		if old, ok := p.workers[voice.Name]; ok {
+			go old.stop()
+		}
+		p.workers[voice.Name] = w
+	}
+	p.mu.Unlock()
+
+	start.worker, start.err = w, err
+	close(start.done)
+	return w, err
+}
+
+func (p *WorkerPool) evictLocked() {
+	var oldestName string
+	var oldest time.Time
+	for name, w := range p.workers {
+		if used := w.idleSince(); oldestName == "" || used.Before(oldest) {
+			oldestName, oldest = name, used
+		}
+	}
+	w := p.workers[oldestName]
+	delete(p.workers, oldestName)
+	p.logger.Info("evicting least recently used piper worker", "voice", oldestName)
+	go func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.stop()
+	}()
+}
+
+func (p *WorkerPool) Evict(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.starting, name)
+	if w, ok := p.workers[name]; ok {
+		delete(p.workers, name)
+		p.logger.Info("stopping piper worker for changed voice", "voice", name)
+		go func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.stop()
+		}()
+	}
+}
+
+func (p *WorkerPool) remove(name string, w *piperWorker) {
+	p.mu.Lock()
+	if p.workers[name] == w {
+		delete(p.workers, name)
+	}
+	p.mu.Unlock()
+	w.stop()
+}
+
+func (p *WorkerPool) reapIdle() {
+	interval := p.idleTimeout / 2
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		for name, w := range p.workers {
+			if time.Since(w.idleSince()) > p.idleTimeout {
+				delete(p.workers, name)
+				p.logger.Info("stopping idle piper worker", "voice", name)
+				go w.stop()
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if p.closing {
+		p.mu.Unlock()
+		return
+	}
+	p.closing = true
+	close(p.done)
+	workers := p.workers
+	p.workers = make(map[string]*piperWorker)
+	p.mu.Unlock()
+
+	for _, w := range workers {
+		w.stop()
+	}
+}
+
+type tailWriter struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+}
+
+func (t *tailWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > t.size {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.size:]...)
+	}
+	return len(b), nil
+}
+
+func (t *tailWriter) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}